@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/mail"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+const (
+	// resetAudience and verifyAudience keep the tokens mailed to users apart
+	// from each other and from access tokens
+	resetAudience  = "password-reset"
+	verifyAudience = "email-verify"
+
+	resetExpiration  = time.Hour
+	verifyExpiration = 7 * 24 * time.Hour
+
+	// stampClaim binds a reset token to the password it resets so the token
+	// is used once
+	stampClaim = "pwd"
+
+	mailTimeout = 30 * time.Second
+)
+
+// ForgotPassword mails a reset link to a registered email, the answer is the
+// same for any email
+func (app *app) ForgotPassword(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var forgot struct {
+		Email string `json:"email"`
+	}
+	if err := jsonFromRequest(&forgot, r); err != nil {
+		return nil, err
+	}
+	if forgot.Email == "" {
+		return nil, errors.Wrap(errMissingParam, "email")
+	}
+
+	user, err := app.Storage.FindUserByEmail(r.Context(), forgot.Email)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stamp, err := app.Storage.PasswordStamp(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := app.mailToken(user, resetAudience, resetExpiration,
+		map[string]interface{}{stampClaim: stamp})
+	if err != nil {
+		return nil, err
+	}
+
+	app.send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nFollow this link within an hour "+
+			"to choose a new password:\n\n%s\n\nIgnore this e-mail if you "+
+			"did not ask for it.\n", user.Nick, app.link("reset", token)),
+	})
+	return nil, nil
+}
+
+// ResetPassword sets the password of the user of a reset token, which also
+// lifts the lockout of its email
+func (app *app) ResetPassword(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var reset struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := jsonFromRequest(&reset, r); err != nil {
+		return nil, err
+	}
+
+	claims, err := app.checkMailToken(reset.Token, resetAudience)
+	if err != nil {
+		return nil, errors.Wrap(errInvalidResetToken, err.Error())
+	}
+	id, _ := strconv.Atoi(claims.Sub)
+	stamp, _ := claims.Custom[stampClaim].(string)
+
+	err = app.Storage.ResetPassword(r.Context(), id, stamp, reset.Password)
+	switch errors.Cause(err) {
+	case nil:
+	case storage.ErrPasswordChanged, storage.ErrUserNotFound:
+		return nil, errors.Wrap(errInvalidResetToken, "already used")
+	default:
+		return nil, err
+	}
+
+	return nil, app.Storage.ClearLoginThrottle(r.Context(),
+		emailKey(claims.Email))
+}
+
+// SendVerification mails a new verification link to the request user
+func (app *app) SendVerification(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+	if user.Verified {
+		return nil, nil
+	}
+
+	return nil, app.sendVerification(user)
+}
+
+// VerifyEmail marks the user of a verification token as verified, the token
+// only verifies the email it was sent to
+func (app *app) VerifyEmail(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	claims, err := app.checkMailToken(r.URL.Query().Get("token"),
+		verifyAudience)
+	if err != nil {
+		return nil, errors.Wrap(errInvalidVerifyToken, err.Error())
+	}
+	id, _ := strconv.Atoi(claims.Sub)
+
+	user, err := app.Storage.VerifyUser(r.Context(), id, claims.Email)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return nil, errors.Wrap(errInvalidVerifyToken, "email changed")
+	}
+	return user, err
+}
+
+// sendVerification mails the verification link of user
+func (app *app) sendVerification(user model.User) error {
+	token, err := app.mailToken(user, verifyAudience, verifyExpiration, nil)
+	if err != nil {
+		return err
+	}
+
+	app.send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your e-mail",
+		Body: fmt.Sprintf("Hello %s,\n\nFollow this link to verify your "+
+			"e-mail and start posting:\n\n%s\n", user.Nick,
+			app.link("verify", token)),
+	})
+	return nil
+}
+
+// mailToken returns a token for user signed with the keys of the access
+// tokens, aud tells what it is for
+func (app *app) mailToken(user model.User, aud string, ttl time.Duration,
+	custom map[string]interface{}) (string, error) {
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return jwt.New(jwt.Payload{
+		Iss:    app.claims.Issuer,
+		Sub:    strconv.Itoa(user.ID),
+		Aud:    jwt.Audience{aud},
+		Exp:    time.Now().Add(ttl).Unix(),
+		Jti:    jti,
+		Email:  user.Email,
+		Custom: custom,
+	}, app.keys).Encode()
+}
+
+// checkMailToken returns the claims of a token issued by mailToken for aud
+func (app *app) checkMailToken(raw, aud string) (jwt.Payload, error) {
+	token := jwt.New(jwt.Payload{}, app.keys)
+	if err := token.Decode(raw); err != nil {
+		return jwt.Payload{}, err
+	}
+	if err := token.Check(jwt.Validator{
+		Issuer:   app.claims.Issuer,
+		Audience: aud,
+		Leeway:   app.claims.Leeway,
+	}); err != nil {
+		return jwt.Payload{}, err
+	}
+	return token.Claims(), nil
+}
+
+// link returns the address of a page of the site handling token
+func (app *app) link(page, token string) string {
+	return app.siteURL + "/#/" + page + "?token=" + url.QueryEscape(token)
+}
+
+// send mails m in the background so answers take as long whether or not a
+// mail is sent, failures are only logged
+func (app *app) send(m mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := app.mailer.Send(ctx, m); err != nil {
+			log.Printf("E: %+v\n", err)
+		}
+	}()
+}
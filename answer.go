@@ -0,0 +1,266 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (app *app) RetrieveQuestionAnswers(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, err := app.Storage.FindAnswerByQuestion(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	voted, err := app.ballots(r, model.AnswerVote)
+	if err != nil {
+		return nil, err
+	}
+	for i := range answers {
+		answers[i].Voted = voted[answers[i].ID]
+	}
+
+	return answers, nil
+}
+
+func (app *app) RetrieveQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	answer.Voted, err = app.ballot(r, model.AnswerVote, answer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+func (app *app) CreateQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var answer model.Answer
+	if err := jsonFromRequest(&answer, r); err != nil {
+		return nil, err
+	}
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	user, err := verifiedUser(r)
+	if err != nil {
+		return nil, err
+	}
+	answer.UserID = user.ID
+	answer.QuestionID = id
+
+	answer, err = app.Storage.CreateAnswer(r.Context(), answer)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), model.Revision{},
+		answerRevision(answer, user.ID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+func (app *app) UpdateQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var edit struct {
+		model.Answer
+		Summary string `json:"summary"`
+	}
+	if err := jsonFromRequest(&edit, r); err != nil {
+		return nil, err
+	}
+	astore, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.ownerOr(r, astore.UserID, model.PermEditPost); err != nil {
+		return nil, errors.Wrap(err, "Cannot update another user answer")
+	}
+	editor, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	edit.ID = astore.ID
+	edit.QuestionID = astore.QuestionID
+	revised := answerRevision(edit.Answer, editor.ID, edit.Summary)
+	if err := revised.Valid(); err != nil {
+		return nil, err
+	}
+
+	answer, err := app.Storage.UpdateAnswer(r.Context(), edit.Answer)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), answerRevision(astore, astore.UserID, ""),
+		answerRevision(answer, editor.ID, edit.Summary))
+	if err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+func (app *app) DeleteQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.ownerOr(r, answer.UserID, model.PermDeletePost); err != nil {
+		return nil, errors.Wrap(err, "Cannot delete another user answer")
+	}
+
+	return nil, app.Storage.DeleteAnswer(r.Context(), answer.ID)
+}
+
+func (app *app) AcceptQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.questionAuthor(r, answer.QuestionID); err != nil {
+		return nil, err
+	}
+
+	if err := app.Storage.AcceptAnswer(r.Context(), answer.QuestionID,
+		answer.ID); err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindAnswer(r.Context(), answer.ID)
+}
+
+func (app *app) UnacceptQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.questionAuthor(r, answer.QuestionID); err != nil {
+		return nil, err
+	}
+	if !answer.Accepted {
+		return nil, errNotAccepted
+	}
+
+	if err := app.Storage.AcceptAnswer(r.Context(), answer.QuestionID,
+		0); err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindAnswer(r.Context(), answer.ID)
+}
+
+func (app *app) UpVoteQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return app.voteAnswer(r, model.VoteUp)
+}
+
+func (app *app) DownVoteQuestionAnswer(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return app.voteAnswer(r, model.VoteDown)
+}
+
+func (app *app) voteAnswer(r *http.Request, direction int) (interface{},
+	error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	voter, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+	if voter.ID == answer.UserID {
+		return nil, errSelfVote
+	}
+
+	vote, err := app.Storage.CastVote(r.Context(), model.Vote{
+		UserID:    voter.ID,
+		Target:    model.AnswerVote,
+		TargetID:  answer.ID,
+		Direction: direction,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err = app.Storage.FindAnswer(r.Context(), answer.ID)
+	if err != nil {
+		return nil, err
+	}
+	answer.Voted = vote.Direction
+
+	return answer, nil
+}
+
+// answerFromRequest loads the {aid} answer making sure it belongs to the {id}
+// question
+func (app *app) answerFromRequest(r *http.Request) (model.Answer, error) {
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return model.Answer{}, err
+	}
+	aid, err := idFromRequest("aid", r)
+	if err != nil {
+		return model.Answer{}, err
+	}
+
+	if _, err := app.Storage.FindQuestion(r.Context(), id); err != nil {
+		return model.Answer{}, err
+	}
+	answer, err := app.Storage.FindAnswer(r.Context(), aid)
+	if err != nil {
+		return model.Answer{}, err
+	}
+	if answer.QuestionID != id {
+		return model.Answer{}, errors.Wrap(storage.ErrAnswerNotFound,
+			"Answer does not belong to question")
+	}
+
+	return answer, nil
+}
+
+// questionAuthor fails unless the request user wrote the question
+func (app *app) questionAuthor(r *http.Request, id int) error {
+	question, err := app.Storage.FindQuestion(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	user, err := requestUser(r)
+	if err != nil {
+		return err
+	}
+	if user.ID != question.UserID {
+		return errors.Wrap(model.ErrForbidden,
+			"Only the question author can accept answers")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+const (
+	// apiTokenPrefix tells API tokens from JWTs and makes leaked tokens easy
+	// to scan for
+	apiTokenPrefix = "qa_pat_"
+	// touchInterval bounds the writes made to track the last use of a token
+	touchInterval = time.Minute
+)
+
+// createdAPIToken answers the creation of a token, the only time its secret
+// is shown
+type createdAPIToken struct {
+	model.APIToken
+	Token string `json:"token"`
+}
+
+func (app *app) CreateAPIToken(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var token model.APIToken
+	if err := jsonFromRequest(&token, r); err != nil {
+		return nil, err
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	secret = apiTokenPrefix + secret
+
+	token.UserID = user.ID
+	token.Hash = hashToken(secret)
+	token, err = app.Storage.CreateAPIToken(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdAPIToken{token, secret}, nil
+}
+
+func (app *app) RetrieveAPITokens(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindAPITokenByUser(r.Context(), user.ID)
+}
+
+func (app *app) DeleteAPIToken(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens of other users are not found so their ids do not leak
+	token, err := app.Storage.FindAPIToken(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if token.UserID != user.ID {
+		return nil, storage.ErrAPITokenNotFound
+	}
+
+	return nil, app.Storage.DeleteAPIToken(r.Context(), id)
+}
+
+// sessionUser returns the user of a request authenticated by a login, API
+// tokens cannot manage tokens as they could grant themselves more scopes
+func sessionUser(r *http.Request) (model.User, error) {
+	if p := principalFrom(r); p.Token != 0 {
+		return model.User{}, errors.Wrap(model.ErrForbidden,
+			"API tokens cannot manage API tokens")
+	}
+	return requestUser(r)
+}
+
+// authenticateAPIToken returns the principal of an API token secret
+func (app *app) authenticateAPIToken(ctx context.Context,
+	secret string) (principal, error) {
+
+	token, err := app.Storage.FindAPITokenByHash(ctx, hashToken(secret))
+	if errors.Cause(err) == storage.ErrAPITokenNotFound ||
+		(err == nil && !token.Active()) {
+		return principal{}, errors.Wrap(errInvalidToken, "unknown API token")
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	user, err := app.Storage.FindUser(ctx, token.UserID)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return principal{}, errors.Wrap(errInvalidToken, "unknown subject")
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	if now := time.Now(); now.Sub(token.LastUsed) > touchInterval {
+		if err := app.Storage.TouchAPIToken(ctx, token.ID, now); err != nil {
+			return principal{}, err
+		}
+	}
+
+	return principal{User: user, Token: token.ID,
+		Scopes: token.Scopes()}, nil
+}
+
+// isAPIToken tells API token secrets from JWTs
+func isAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, apiTokenPrefix)
+}
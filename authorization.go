@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+)
+
+// principal is the verified identity of a request, Validate stores it in the
+// request context. Logins carry their Session and every scope, API tokens
+// their Token id and the scopes they were granted.
+type principal struct {
+	User    model.User
+	Session string
+	Token   int
+	Claims  jwt.Payload
+	Scopes  []string
+}
+
+// allows tells whether the principal was granted scope, the empty scope is
+// granted to all
+func (p principal) allows(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFrom returns the principal of r, the zero principal when the
+// request is anonymous
+func principalFrom(r *http.Request) principal {
+	p, _ := r.Context().Value(principalKey{}).(principal)
+	return p
+}
+
+// authorize runs fn only when the request was granted scope and the role of
+// the request user grants perm
+func authorize(perm model.Permission, scope string, fn appHandler) appHandler {
+	if perm == "" && scope == "" {
+		return fn
+	}
+	return func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+		p := principalFrom(r)
+		if !p.allows(scope) {
+			return nil, errors.Wrapf(model.ErrForbidden, "%s scope required",
+				scope)
+		}
+		if !model.Can(p.User.Role, perm) {
+			return nil, errors.Wrapf(model.ErrForbidden, "%s required",
+				perm)
+		}
+		return fn(w, r)
+	}
+}
+
+// ownerOr fails unless the request user is author or its role grants perm
+func (app *app) ownerOr(r *http.Request, author int,
+	perm model.Permission) error {
+
+	user, err := requestUser(r)
+	if err != nil {
+		return err
+	}
+	if user.ID == author {
+		return nil
+	}
+	// acting on posts of others is an admin task for API tokens
+	if model.Can(user.Role, perm) && principalFrom(r).allows(model.ScopeAdmin) {
+		return nil
+	}
+	return model.ErrForbidden
+}
+
+// requestUser returns the authenticated user of r
+func requestUser(r *http.Request) (model.User, error) {
+	p := principalFrom(r)
+	if p.Session == "" && p.Token == 0 {
+		return model.User{}, errMissingToken
+	}
+	return p.User, nil
+}
+
+// verifiedUser returns the authenticated user of r once its email is
+// verified, posting requires it
+func verifiedUser(r *http.Request) (model.User, error) {
+	user, err := requestUser(r)
+	if err != nil {
+		return model.User{}, err
+	}
+	if !user.Verified {
+		return model.User{}, errUnverified
+	}
+	return user, nil
+}
+
+// bootstrapAdmin grants the admin role to the user registered with email so a
+// fresh install gets its first admin
+func (app *app) bootstrapAdmin(ctx context.Context, email string) error {
+	user, err := app.Storage.FindUserByEmail(ctx, email)
+	if err != nil {
+		return errors.Wrapf(err, "cannot find admin %s", email)
+	}
+	if user.Role == model.RoleAdmin {
+		return nil
+	}
+	_, err = app.Storage.SetUserRole(ctx, user.ID, model.RoleAdmin)
+	return err
+}
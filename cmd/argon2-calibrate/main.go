@@ -0,0 +1,74 @@
+// Command argon2-calibrate picks the argon2 parameters of password hashes
+// taking at most a target duration on this host, the result is printed as the
+// flags of the server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+)
+
+// maxTime bounds the passes tried
+const maxTime = 64
+
+// measure returns the duration of hashing with parameters, the best of a few
+// runs so a busy host does not inflate it
+func measure(parameters *argon2.ARGON2, runs int) time.Duration {
+	best := time.Duration(0)
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		if _, err := argon2.GenerateFromPassword([]byte("calibration"), nil,
+			parameters); err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		if d := time.Since(start); best == 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+func main() {
+	target := flag.Duration("target", 500*time.Millisecond,
+		"longest hash duration")
+	memory := flag.Uint("memory", 64*1024, "KiB of memory, halved when a "+
+		"single pass exceeds the target")
+	threads := flag.Uint("threads", uint(runtime.NumCPU()), "threads")
+	runs := flag.Int("runs", 3, "measures of each candidate")
+	flag.Parse()
+
+	if *threads == 0 || *threads > 255 || *memory < 8*uint(*threads) {
+		log.Fatalf("invalid parameters: memory %d KiB, threads %d\n",
+			*memory, *threads)
+	}
+
+	// memory matters most against cracking hardware, so it is only lowered
+	// when a single pass is already too slow
+	m, p := uint32(*memory), uint8(*threads)
+	for m > 8*uint32(p) && measure(argon2.New(argon2.WithTime(1),
+		argon2.WithMemory(m), argon2.WithThreads(p)), *runs) > *target {
+		m /= 2
+	}
+
+	// the most passes staying within the target
+	t := uint32(1)
+	d := measure(argon2.New(argon2.WithTime(t), argon2.WithMemory(m),
+		argon2.WithThreads(p)), *runs)
+	for t < maxTime {
+		next := measure(argon2.New(argon2.WithTime(t+1), argon2.WithMemory(m),
+			argon2.WithThreads(p)), *runs)
+		if next > *target {
+			break
+		}
+		t, d = t+1, next
+	}
+
+	log.Printf("m=%d,t=%d,p=%d hashes in %s\n", m, t, p, d)
+	fmt.Printf("-argon2-time %d -argon2-memory %d -argon2-threads %d\n", t, m,
+		p)
+}
@@ -0,0 +1,20 @@
+// Command highlight-css prints the stylesheet of a chroma style for the code
+// blocks the server highlights, the frontend themes them by swapping it.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
+)
+
+func main() {
+	style := flag.String("style", "github", "chroma style")
+	flag.Parse()
+
+	if err := markdown.WriteCSS(os.Stdout, *style); err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+}
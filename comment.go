@@ -4,8 +4,8 @@ import (
 	"net/http"
 
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/jwt"
 	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 func (app *app) RetrieveQuestionComments(w http.ResponseWriter,
@@ -16,7 +16,24 @@ func (app *app) RetrieveQuestionComments(w http.ResponseWriter,
 		return nil, err
 	}
 
-	return app.Storage.FindCommentByQuestion(id)
+	query, err := queryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	comments, next, err := app.Storage.FindCommentByQuestion(r.Context(), id,
+		query)
+	if err != nil {
+		return nil, err
+	}
+	voted, err := app.ballots(r, model.CommentVote)
+	if err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		comments[i].Voted = voted[comments[i].ID]
+	}
+
+	return paged{comments, next}, nil
 }
 
 func (app *app) RetrieveQuestionComment(w http.ResponseWriter,
@@ -31,11 +48,20 @@ func (app *app) RetrieveQuestionComment(w http.ResponseWriter,
 		return nil, err
 	}
 
-	if _, err := app.Storage.FindQuestion(id); err != nil {
+	if _, err := app.Storage.FindQuestion(r.Context(), id); err != nil {
+		return nil, err
+	}
+
+	comment, err := app.Storage.FindComment(r.Context(), cid)
+	if err != nil {
+		return nil, err
+	}
+	comment.Voted, err = app.ballot(r, model.CommentVote, cid)
+	if err != nil {
 		return nil, err
 	}
 
-	return app.Storage.FindComment(cid)
+	return comment, nil
 }
 
 func (app *app) CreateQuestionComments(w http.ResponseWriter,
@@ -49,22 +75,35 @@ func (app *app) CreateQuestionComments(w http.ResponseWriter,
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	user, err := app.Storage.FindUserByEmail(payload.Email)
+	user, err := verifiedUser(r)
 	if err != nil {
 		return nil, err
 	}
 	comment.UserID = user.ID
 	comment.QuestionID = id
+	comment.AnswerID = 0
 
-	return app.Storage.CreateComment(comment)
+	comment, err = app.Storage.CreateComment(r.Context(), comment)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), model.Revision{},
+		commentRevision(comment, user.ID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
 }
 
 func (app *app) UpdateQuestionComment(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
-	var comment model.Comment
-	if err := jsonFromRequest(&comment, r); err != nil {
+	var edit struct {
+		model.Comment
+		Summary string `json:"summary"`
+	}
+	if err := jsonFromRequest(&edit, r); err != nil {
 		return nil, err
 	}
 	id, err := idFromRequest("id", r)
@@ -76,31 +115,82 @@ func (app *app) UpdateQuestionComment(w http.ResponseWriter,
 		return nil, err
 	}
 
-	payload := jwt.DecodePayload(r)
-	if _, err := app.Storage.FindQuestion(id); err != nil {
+	if _, err := app.Storage.FindQuestion(r.Context(), id); err != nil {
 		return nil, err
 	}
-	cstore, err := app.Storage.FindComment(cid)
+	cstore, err := app.Storage.FindComment(r.Context(), cid)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(cstore.UserID)
+	if err := app.ownerOr(r, cstore.UserID, model.PermEditPost); err != nil {
+		return nil, errors.Wrap(err, "Cannot update another user comment")
+	}
+	editor, err := requestUser(r)
 	if err != nil {
 		return nil, err
 	}
-	if payload.Email != author.Email {
-		return nil, errors.Errorf("Cannot update another user comment")
+
+	edit.ID = cid
+	edit.QuestionID = id
+	revised := commentRevision(edit.Comment, editor.ID, edit.Summary)
+	if err := revised.Valid(); err != nil {
+		return nil, err
 	}
 
-	comment.ID = cid
-	comment.QuestionID = id
+	comment, err := app.Storage.UpdateComment(r.Context(), edit.Comment)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), commentRevision(cstore, cstore.UserID, ""),
+		commentRevision(comment, editor.ID, edit.Summary))
+	if err != nil {
+		return nil, err
+	}
 
-	return app.Storage.UpdateComment(comment)
+	return comment, nil
+}
+
+func (app *app) DeleteQuestionComment(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	cid, err := idFromRequest("cid", r)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := app.Storage.FindComment(r.Context(), cid)
+	if err != nil {
+		return nil, err
+	}
+	if comment.QuestionID != id {
+		return nil, errors.Wrap(storage.ErrCommentNotFound,
+			"Comment does not belong to question")
+	}
+	if err := app.ownerOr(r, comment.UserID, model.PermDeletePost); err != nil {
+		return nil, errors.Wrap(err, "Cannot delete another user comment")
+	}
+
+	return nil, app.Storage.DeleteComment(r.Context(), cid)
 }
 
 func (app *app) UpVoteQuestionComment(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
+	return app.voteComment(r, model.VoteUp)
+}
+
+func (app *app) DownVoteQuestionComment(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return app.voteComment(r, model.VoteDown)
+}
+
+func (app *app) voteComment(r *http.Request, direction int) (interface{},
+	error) {
+
 	id, err := idFromRequest("id", r)
 	if err != nil {
 		return nil, err
@@ -110,52 +200,91 @@ func (app *app) UpVoteQuestionComment(w http.ResponseWriter,
 		return nil, err
 	}
 
-	if _, err := app.Storage.FindQuestion(id); err != nil {
+	if _, err := app.Storage.FindQuestion(r.Context(), id); err != nil {
 		return nil, err
 	}
-	comment, err := app.Storage.FindComment(cid)
+	comment, err := app.Storage.FindComment(r.Context(), cid)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(comment.UserID)
+	voter, err := requestUser(r)
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	if payload.Email == author.Email {
-		return nil, errors.Errorf("Cannot up vote yourself")
+	if voter.ID == comment.UserID {
+		return nil, errSelfVote
+	}
+
+	vote, err := app.Storage.CastVote(r.Context(), model.Vote{
+		UserID:    voter.ID,
+		Target:    model.CommentVote,
+		TargetID:  cid,
+		Direction: direction,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err = app.Storage.FindComment(r.Context(), cid)
+	if err != nil {
+		return nil, err
 	}
+	comment.Voted = vote.Direction
 
-	return nil, app.Storage.UpComment(cid)
+	return comment, nil
 }
 
-func (app *app) DownVoteQuestionComment(w http.ResponseWriter,
+func (app *app) RetrieveAnswerComments(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
-	id, err := idFromRequest("id", r)
+	answer, err := app.answerFromRequest(r)
 	if err != nil {
 		return nil, err
 	}
-	cid, err := idFromRequest("cid", r)
+
+	comments, err := app.Storage.FindCommentByAnswer(r.Context(), answer.ID)
 	if err != nil {
 		return nil, err
 	}
+	voted, err := app.ballots(r, model.CommentVote)
+	if err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		comments[i].Voted = voted[comments[i].ID]
+	}
+
+	return comments, nil
+}
 
-	if _, err := app.Storage.FindQuestion(id); err != nil {
+func (app *app) CreateAnswerComments(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var comment model.Comment
+	if err := jsonFromRequest(&comment, r); err != nil {
 		return nil, err
 	}
-	comment, err := app.Storage.FindComment(cid)
+	answer, err := app.answerFromRequest(r)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(comment.UserID)
+	user, err := verifiedUser(r)
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	if payload.Email == author.Email {
-		return nil, errors.Errorf("Cannot up vote yourself")
+	comment.UserID = user.ID
+	comment.QuestionID = answer.QuestionID
+	comment.AnswerID = answer.ID
+
+	comment, err = app.Storage.CreateComment(r.Context(), comment)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), model.Revision{},
+		commentRevision(comment, user.ID, ""))
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, app.Storage.DownComment(cid)
+	return comment, nil
 }
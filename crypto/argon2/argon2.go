@@ -1,7 +1,9 @@
 package argon2
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
@@ -18,6 +20,13 @@ const (
 	defaultThreads = 4
 	defaultLength  = 32
 	defaultVersion = 0x13
+
+	saltLength = 16
+	// minSalt and minLength are the shortest salt and key of RFC 9106,
+	// maxLength bounds the key derived for a parsed hash
+	minSalt   = 8
+	minLength = 4
+	maxLength = 1024
 )
 
 type ARGON2 struct {
@@ -28,8 +37,12 @@ type ARGON2 struct {
 
 type ARGON2Option func(*ARGON2)
 
+// policy holds the parameters of new hashes, hashes made with other ones
+// need a rehash
+var policy = ARGON2{defaultTime, defaultMemory, defaultThreads, defaultLength}
+
 func New(options ...ARGON2Option) *ARGON2 {
-	argon := ARGON2{defaultTime, defaultMemory, defaultThreads, defaultLength}
+	argon := policy
 
 	for _, option := range options {
 		option(&argon)
@@ -38,16 +51,57 @@ func New(options ...ARGON2Option) *ARGON2 {
 	return &argon
 }
 
-func newSalt() (string, error) {
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
-	if err != nil {
-		errors.Wrap(err, "unable to generate salt")
+// WithTime sets the number of passes over the memory
+func WithTime(time uint32) ARGON2Option {
+	return func(a *ARGON2) { a.time = time }
+}
+
+// WithMemory sets the memory used in KiB
+func WithMemory(memory uint32) ARGON2Option {
+	return func(a *ARGON2) { a.memory = memory }
+}
+
+// WithThreads sets the degree of parallelism
+func WithThreads(threads uint8) ARGON2Option {
+	return func(a *ARGON2) { a.threads = threads }
+}
+
+// SetPolicy sets the parameters of new hashes to the defaults changed by
+// options, it is meant to be called once at startup before any hash is made
+func SetPolicy(options ...ARGON2Option) {
+	argon := ARGON2{defaultTime, defaultMemory, defaultThreads, defaultLength}
+	for _, option := range options {
+		option(&argon)
+	}
+	policy = argon
+}
+
+// String formats the parameters the way they are encoded in hashes
+func (a ARGON2) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", a.memory, a.time, a.threads)
+}
+
+// Parameters returns the parameters new hashes are encoded with, the current
+// pepper included, so hashes of equal parameters cost the same to verify
+func Parameters() string {
+	params := New().String()
+	if pepper, ok := currentPepper(); ok {
+		params += pepper.param()
+	}
+	return params
+}
+
+func newSalt() ([]byte, error) {
+	b := make([]byte, saltLength)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
 	}
-	return base64.RawStdEncoding.EncodeToString(b), nil
+	return b, nil
 }
 
-// GenerateFromPassword hash password using argon2id and return phc format string
+// GenerateFromPassword hashes password with argon2id and the current pepper
+// and returns the PHC string $argon2id$v=19$m=,t=,p=[,keyid=]$salt$hash, a nil
+// salt is random
 func GenerateFromPassword(password, salt []byte, parameters *ARGON2) (string, error) {
 
 	if parameters == nil {
@@ -59,98 +113,183 @@ func GenerateFromPassword(password, salt []byte, parameters *ARGON2) (string, er
 		if err != nil {
 			return "", err
 		}
-		salt = []byte(rsalt)
+		salt = rsalt
+	}
+
+	params := parameters.String()
+	if pepper, ok := currentPepper(); ok {
+		password = pepper.mix(password)
+		params += pepper.param()
 	}
 
 	pass := argon2.IDKey(password, salt, parameters.time,
 		parameters.memory, parameters.threads, parameters.keyLen)
 
-	return fmt.Sprintf("$argon2id$v=%d$m=%d$t=%d$p=%d$%s$%s", defaultVersion,
-		parameters.memory, parameters.time, parameters.threads, string(salt),
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", defaultVersion, params,
+		base64.RawStdEncoding.EncodeToString(salt),
 		base64.RawStdEncoding.EncodeToString(pass)), nil
 }
 
-func newFromHash(hash string) (*ARGON2, []byte, error) {
-
-	var argontype string
-	var time, memory, threads uint64
+// hash is a parsed argon2id hash, pepper is the id of the pepper it was made
+// with
+type hash struct {
+	argon  ARGON2
+	salt   []byte
+	key    []byte
+	pepper string
+	legacy bool
+}
 
-	parts := strings.Split(hash[1:], "$")
-	if len(parts) != 7 {
-		return nil, nil, errors.New("incorrect number of segments on hash")
+// parse reads canonical PHC hashes and, in legacy mode, the hashes of the
+// former $argon2id$v=19$m=$t=$p=$salt$hash format whose salt was used as
+// written
+func parse(hashed string) (hash, error) {
+	if !strings.HasPrefix(hashed, "$") {
+		return hash{}, errors.New("incorrect hash format")
 	}
-
-	argontype = parts[0]
-	if argontype != "argon2id" {
-		return nil, nil, errors.New("incorrect argon2 type")
+	parts := strings.Split(hashed[1:], "$")
+	if parts[0] != "argon2id" {
+		return hash{}, errors.New("incorrect argon2 type")
 	}
-
-	if parts[1] != ("v=" + strconv.Itoa(defaultVersion)) {
-		return nil, nil, errors.New("incorrect version")
+	if len(parts) < 2 || parts[1] != ("v="+strconv.Itoa(defaultVersion)) {
+		return hash{}, errors.New("incorrect version")
 	}
 
-	rmemory, err := splitAndGet(parts[2], "=", 1)
-	if err != nil {
-		return nil, nil, err
+	var h hash
+	var params []string
+	switch len(parts) {
+	case 5:
+		params = strings.Split(parts[2], ",")
+		salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+		if err != nil {
+			return hash{}, errors.New("cannot decode salt")
+		}
+		h.salt = salt
+	case 7:
+		if !Legacy() {
+			return hash{}, errors.New("legacy hash format disabled")
+		}
+		params = parts[2:5]
+		h.salt = []byte(parts[5])
+		h.legacy = true
+	default:
+		return hash{}, errors.New("incorrect number of segments on hash")
 	}
-	memory, err = strconv.ParseUint(rmemory, 10, 32)
+	key, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
 	if err != nil {
-		return nil, nil, errors.New("cannot convert memory value")
+		return hash{}, errors.New("cannot decode key")
 	}
+	h.key = key
 
-	rtime, err := splitAndGet(parts[3], "=", 1)
-	if err != nil {
-		return nil, nil, err
-	}
-	time, err = strconv.ParseUint(rtime, 10, 32)
-	if err != nil {
-		return nil, nil, errors.New("cannot convert time value")
+	if err := h.parseParams(params); err != nil {
+		return hash{}, err
 	}
-
-	rthreads, err := splitAndGet(parts[4], "=", 1)
-	if err != nil {
-		return nil, nil, err
+	if len(h.salt) < minSalt {
+		return hash{}, errors.New("salt too short")
 	}
-	threads, err = strconv.ParseUint(rthreads, 10, 32)
-	if err != nil {
-		return nil, nil, errors.New("cannot convert threads value")
+	if len(h.key) < minLength || len(h.key) > maxLength {
+		return hash{}, errors.New("invalid key length")
 	}
+	h.argon.keyLen = uint32(len(h.key))
 
-	salt := parts[5]
+	return h, nil
+}
 
-	argon := New(
-		func(a *ARGON2) {
-			a.time = uint32(time)
-			a.memory = uint32(memory)
-			a.threads = uint8(threads)
-		},
-	)
+func (h *hash) parseParams(params []string) error {
+	seen := map[string]bool{}
+	for _, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || seen[kv[0]] {
+			return errors.Errorf("incorrect parameter %s", param)
+		}
+		seen[kv[0]] = true
 
-	return argon, []byte(salt), nil
-}
+		if kv[0] == "keyid" {
+			id, err := base64.RawStdEncoding.DecodeString(kv[1])
+			if err != nil {
+				return errors.New("cannot decode keyid")
+			}
+			h.pepper = string(id)
+			continue
+		}
 
-func splitAndGet(s, sep string, index int) (string, error) {
-	split := strings.Split(s, sep)
-	if len(split) <= index {
-		return "", errors.New("not enough elements to segment")
+		value, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return errors.Errorf("cannot convert %s value", kv[0])
+		}
+		switch kv[0] {
+		case "m":
+			h.argon.memory = uint32(value)
+		case "t":
+			h.argon.time = uint32(value)
+		case "p":
+			if value > 255 {
+				return errors.New("too many threads")
+			}
+			h.argon.threads = uint8(value)
+		default:
+			return errors.Errorf("unknown parameter %s", kv[0])
+		}
+	}
+	if h.argon.time == 0 || h.argon.threads == 0 ||
+		h.argon.memory < 8*uint32(h.argon.threads) {
+		return errors.New("incorrect parameters")
 	}
-	return split[index], nil
+	return nil
 }
 
 func CompareHashAndPassword(hashed, pass []byte) error {
-	argon, salt, err := newFromHash(string(hashed))
+	h, err := parse(string(hashed))
 	if err != nil {
 		return err
 	}
 
-	expected, err := GenerateFromPassword(pass, salt, argon)
-	if err != nil {
-		return err
+	if h.pepper != "" {
+		pepper, ok := findPepper(h.pepper)
+		if !ok {
+			return errors.Errorf("unknown pepper %q", h.pepper)
+		}
+		pass = pepper.mix(pass)
 	}
+	expected := argon2.IDKey(pass, h.salt, h.argon.time, h.argon.memory,
+		h.argon.threads, h.argon.keyLen)
 
-	if subtle.ConstantTimeCompare([]byte(expected), hashed) == 1 {
+	if subtle.ConstantTimeCompare(expected, h.key) == 1 {
 		return nil
 	}
 
 	return errors.New("not equal hash and password")
 }
+
+// NeedsRehash tells whether hashed was made with parameters other than those
+// of the current policy, a nil policy is the one of New, or with another
+// pepper. Legacy hashes and hashes that cannot be parsed need a rehash too.
+func NeedsRehash(hashed []byte, parameters *ARGON2) bool {
+	if parameters == nil {
+		parameters = New()
+	}
+
+	h, err := parse(string(hashed))
+	if err != nil || h.legacy {
+		return true
+	}
+	pepper, _ := currentPepper()
+
+	return h.argon.time != parameters.time ||
+		h.argon.memory != parameters.memory ||
+		h.argon.threads != parameters.threads ||
+		h.argon.keyLen != parameters.keyLen ||
+		h.pepper != pepper.ID
+}
+
+// param is the keyid parameter naming the pepper in hashes
+func (p Pepper) param() string {
+	return ",keyid=" + base64.RawStdEncoding.EncodeToString([]byte(p.ID))
+}
+
+// mix keys password with the pepper
+func (p Pepper) mix(password []byte) []byte {
+	mac := hmac.New(sha256.New, p.Key)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,137 @@
+package argon2
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// cheap keeps the hashes of the tests fast
+var cheap = New(WithTime(1), WithMemory(64), WithThreads(1))
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		peppers []Pepper
+		prefix  string
+	}{
+		{"unpeppered", nil, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"peppered", []Pepper{{"old", []byte("k1")}, {"new", []byte("k2")}},
+			"$argon2id$v=19$m=64,t=1,p=1,keyid=bmV3$"},
+	}
+	defer SetPeppers()
+	for _, test := range tests {
+		if err := SetPeppers(test.peppers...); err != nil {
+			t.Fatal(err)
+		}
+		hashed, err := GenerateFromPassword([]byte("secret"), nil, cheap)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !strings.HasPrefix(hashed, test.prefix) {
+			t.Errorf("%s: hash %s, want prefix %s", test.name, hashed,
+				test.prefix)
+		}
+		if len(strings.Split(hashed, "$")) != 6 {
+			t.Errorf("%s: hash %s is not a PHC string", test.name, hashed)
+		}
+		if err := CompareHashAndPassword([]byte(hashed),
+			[]byte("secret")); err != nil {
+			t.Errorf("%s: password rejected: %v", test.name, err)
+		}
+		if CompareHashAndPassword([]byte(hashed), []byte("Secret")) == nil {
+			t.Errorf("%s: wrong password accepted", test.name)
+		}
+		if NeedsRehash([]byte(hashed), cheap) {
+			t.Errorf("%s: fresh hash needs a rehash", test.name)
+		}
+	}
+}
+
+func TestFixedSalt(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	first, err := GenerateFromPassword([]byte("secret"), salt, cheap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := GenerateFromPassword([]byte("secret"), salt, cheap)
+	if first != second {
+		t.Errorf("hashes with the same salt differ: %s, %s", first, second)
+	}
+	encoded := base64.RawStdEncoding.EncodeToString(salt)
+	if strings.Split(first, "$")[4] != encoded {
+		t.Errorf("hash %s, want salt %s", first, encoded)
+	}
+}
+
+// legacyHash builds a hash of the former 7 segments format, its salt is used
+// as written
+func legacyHash(pass, salt string) string {
+	key := argon2.IDKey([]byte(pass), []byte(salt), 1, 64, 1, 32)
+	return fmt.Sprintf("$argon2id$v=19$m=64$t=1$p=1$%s$%s", salt,
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestLegacy(t *testing.T) {
+	hashed := []byte(legacyHash("secret", "saltsaltsalt"))
+	defer SetLegacy(true)
+
+	tests := []struct {
+		legacy bool
+		pass   string
+		ok     bool
+	}{
+		{true, "secret", true},
+		{true, "wrong", false},
+		{false, "secret", false},
+	}
+	for _, test := range tests {
+		SetLegacy(test.legacy)
+		err := CompareHashAndPassword(hashed, []byte(test.pass))
+		if (err == nil) != test.ok {
+			t.Errorf("legacy %t, password %s: got %v, want ok %t",
+				test.legacy, test.pass, err, test.ok)
+		}
+	}
+
+	SetLegacy(true)
+	if !NeedsRehash(hashed, cheap) {
+		t.Error("legacy hash does not need a rehash")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	tests := []struct {
+		name   string
+		hashed string
+	}{
+		{"no prefix", "argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{"argon2i", "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{"version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{"segments", "$argon2id$v=19$m=64,t=1,p=1$" + key},
+		{"unknown parameter", "$argon2id$v=19$m=64,t=1,p=1,x=1$" + salt +
+			"$" + key},
+		{"repeated parameter", "$argon2id$v=19$m=64,m=64,t=1,p=1$" + salt +
+			"$" + key},
+		{"no time", "$argon2id$v=19$m=64,p=1$" + salt + "$" + key},
+		{"memory below threads", "$argon2id$v=19$m=8,t=1,p=2$" + salt + "$" +
+			key},
+		{"short salt", "$argon2id$v=19$m=64,t=1,p=1$AAAA$" + key},
+		{"short key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$AAAA"},
+		{"unknown pepper", "$argon2id$v=19$m=64,t=1,p=1,keyid=eA$" + salt +
+			"$" + key},
+	}
+	for _, test := range tests {
+		if CompareHashAndPassword([]byte(test.hashed), nil) == nil {
+			t.Errorf("%s: %s accepted", test.name, test.hashed)
+		}
+		if !NeedsRehash([]byte(test.hashed), cheap) {
+			t.Errorf("%s: %s does not need a rehash", test.name, test.hashed)
+		}
+	}
+}
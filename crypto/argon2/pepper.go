@@ -0,0 +1,128 @@
+package argon2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxPepperID is the longest keyid of the argon2 PHC format
+const maxPepperID = 8
+
+// Pepper is a secret key mixed into passwords with HMAC-SHA256 before they
+// are hashed, kept out of the storage so a stolen database is not enough to
+// crack them. Hashes name their pepper by ID in the keyid parameter so
+// peppers can rotate.
+type Pepper struct {
+	ID  string
+	Key []byte
+}
+
+var (
+	mu      sync.RWMutex
+	peppers []Pepper
+	// legacy verifies the hashes of the former format
+	legacy = true
+)
+
+// SetPeppers replaces the known peppers, the last one peppers new hashes and
+// the others only verify. No pepper leaves new hashes unpeppered.
+func SetPeppers(set ...Pepper) error {
+	ids := map[string]bool{}
+	for _, pepper := range set {
+		if pepper.ID == "" || len(pepper.ID) > maxPepperID {
+			return errors.Errorf("pepper id %q must have 1 to %d bytes",
+				pepper.ID, maxPepperID)
+		}
+		if len(pepper.Key) == 0 {
+			return errors.Errorf("empty pepper %s", pepper.ID)
+		}
+		if ids[pepper.ID] {
+			return errors.Errorf("duplicate pepper %s", pepper.ID)
+		}
+		ids[pepper.ID] = true
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	peppers = append([]Pepper(nil), set...)
+	return nil
+}
+
+// LoadPeppers reads the peppers of path, a directory of pepper files sorted
+// by name or a single file. Pepper ids are the file names without extension.
+func LoadPeppers(path string) ([]Pepper, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load peppers")
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load peppers")
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	loaded := []Pepper{}
+	for _, file := range files {
+		key, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load pepper %s", file)
+		}
+		name := filepath.Base(file)
+		loaded = append(loaded, Pepper{
+			ID:  strings.TrimSuffix(name, filepath.Ext(name)),
+			Key: bytes.TrimSpace(key),
+		})
+	}
+	return loaded, nil
+}
+
+// SetLegacy turns the verification of hashes of the former format on or off,
+// it is on until every such hash was upgraded on login
+func SetLegacy(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	legacy = enabled
+}
+
+// Legacy tells whether hashes of the former format are verified
+func Legacy() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return legacy
+}
+
+func currentPepper() (Pepper, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(peppers) == 0 {
+		return Pepper{}, false
+	}
+	return peppers[len(peppers)-1], true
+}
+
+func findPepper(id string) (Pepper, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, pepper := range peppers {
+		if pepper.ID == id {
+			return pepper, true
+		}
+	}
+	return Pepper{}, false
+}
@@ -0,0 +1,154 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Breaches looks passwords up in a local copy of the Have I Been Pwned
+// passwords: either a file of HASH:COUNT lines sorted by SHA-1 hash, or a
+// directory of range files named by the first 5 hex digits of the hashes,
+// optionally with a .txt extension, holding SUFFIX:COUNT lines
+type Breaches struct {
+	path string
+	dir  bool
+}
+
+// OpenBreaches opens the breached passwords file or directory of path
+func OpenBreaches(path string) (*Breaches, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open breached passwords")
+	}
+	return &Breaches{path, info.IsDir()}, nil
+}
+
+// Count returns how many times pass appeared in breaches
+func (b *Breaches) Count(pass string) (int, error) {
+	sum := sha1.Sum([]byte(pass))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if !b.dir {
+		return b.search(hash)
+	}
+
+	// a missing range holds no breached password
+	prefix, suffix := hash[:5], hash[5:]
+	f, err := os.Open(filepath.Join(b.path, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(b.path, prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot read breached passwords")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if count, ok := breachCount(scanner.Text(), suffix); ok {
+			return count, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, "cannot read breached passwords")
+	}
+	return 0, nil
+}
+
+// search binary searches hash in the sorted file of b, comparing the first
+// full line after each offset so the file is never read whole
+func (b *Breaches) search(hash string) (int, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot read breached passwords")
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot read breached passwords")
+	}
+
+	low, high := int64(0), info.Size()
+	for low < high {
+		mid := (low + high) / 2
+		line, err := lineAfter(f, mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == "" || strings.ToUpper(line) >= hash {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	// the first line from low is the first line not before hash
+	line, err := lineAfter(f, low)
+	if err != nil {
+		return 0, err
+	}
+	if count, ok := breachCount(line, hash); ok {
+		return count, nil
+	}
+	return 0, nil
+}
+
+// lineAfter returns the first line starting at offset or after, empty past
+// the last line
+func lineAfter(f *os.File, offset int64) (string, error) {
+	if offset == 0 {
+		return lineAt(f, 0)
+	}
+	buf := make([]byte, 256)
+	for {
+		n, err := f.ReadAt(buf, offset-1)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return lineAt(f, offset+int64(i))
+		}
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "cannot read breached passwords")
+		}
+		offset += int64(n)
+	}
+}
+
+// lineAt returns the line starting at offset
+func lineAt(f *os.File, offset int64) (string, error) {
+	buf := make([]byte, 256)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "cannot read breached passwords")
+	}
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+// breachCount parses the count of a HASH:COUNT line of hash
+func breachCount(line, hash string) (int, bool) {
+	line = strings.TrimSpace(line)
+	sep := strings.IndexByte(line, ':')
+	if sep < 0 || !strings.EqualFold(line[:sep], hash) {
+		return 0, false
+	}
+	count, err := strconv.Atoi(line[sep+1:])
+	if err != nil {
+		return 0, false
+	}
+	return count, true
+}
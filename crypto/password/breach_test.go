@@ -0,0 +1,114 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// breach is a breached password, its upper case SHA-1 and count
+type breach struct {
+	pass, hash string
+	count      int
+}
+
+func breaches(n int) []breach {
+	found := make([]breach, n)
+	for i := range found {
+		pass := fmt.Sprintf("breached-%d", i)
+		sum := sha1.Sum([]byte(pass))
+		found[i] = breach{pass, strings.ToUpper(hex.EncodeToString(sum[:])),
+			i + 1}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].hash < found[j].hash
+	})
+	return found
+}
+
+func TestBreachesFile(t *testing.T) {
+	found := breaches(1000)
+	lines := make([]string, len(found))
+	for i, b := range found {
+		lines[i] = fmt.Sprintf("%s:%d", b.hash, b.count)
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenBreaches(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := found[len(found)-1]
+	tests := []struct {
+		name  string
+		pass  string
+		count int
+	}{
+		{"first line", found[0].pass, found[0].count},
+		{"second line", found[1].pass, found[1].count},
+		{"middle line", found[500].pass, found[500].count},
+		{"last line", last.pass, last.count},
+		{"not breached", "not breached", 0},
+	}
+	for _, test := range tests {
+		count, err := b.Count(test.pass)
+		if err != nil || count != test.count {
+			t.Errorf("%s: Count = %d, %v, want %d", test.name, count, err,
+				test.count)
+		}
+	}
+}
+
+func TestBreachesDirectory(t *testing.T) {
+	found := breaches(3)
+	dir := t.TempDir()
+	for i, b := range found {
+		// range files are named with or without the .txt extension
+		name := b.hash[:5]
+		if i == 1 {
+			name += ".txt"
+		}
+		content := fmt.Sprintf("%s:%d\n", b.hash[5:], b.count)
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content),
+			0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := OpenBreaches(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		pass  string
+		count int
+	}{
+		{"range file", found[0].pass, found[0].count},
+		{"range file with extension", found[1].pass, found[1].count},
+		{"missing prefix", "not breached", 0},
+	}
+	for _, test := range tests {
+		count, err := b.Count(test.pass)
+		if err != nil || count != test.count {
+			t.Errorf("%s: Count = %d, %v, want %d", test.name, count, err,
+				test.count)
+		}
+	}
+}
+
+func TestOpenBreachesMissing(t *testing.T) {
+	if _, err := OpenBreaches(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Error("OpenBreaches accepted a missing path")
+	}
+}
@@ -0,0 +1,45 @@
+package password
+
+// dictionary lists common passwords and words, most guessed first
+var dictionary = []string{
+	"password", "123456", "qwerty", "letmein", "welcome", "monkey",
+	"dragon", "football", "baseball", "iloveyou", "admin", "master",
+	"sunshine", "princess", "shadow", "superman", "michael", "jordan",
+	"trustno", "hello", "freedom", "whatever", "starwars", "computer",
+	"secret", "login", "passw", "pass", "abc", "love", "access", "batman",
+	"charlie", "donald", "flower", "hunter", "killer", "soccer", "hockey",
+	"ranger", "buster", "thomas", "tigger", "robert", "summer", "winter",
+	"spring", "autumn", "ginger", "pepper", "cheese", "cookie", "banana",
+	"orange", "apple", "purple", "yellow", "silver", "golden", "diamond",
+	"money", "dollar", "lucky", "happy", "angel", "devil", "heaven",
+	"jesus", "god", "family", "friend", "lover", "sexy", "baby", "honey",
+	"mother", "father", "sister", "brother", "daddy", "mommy", "dog",
+	"cat", "tiger", "lion", "eagle", "horse", "bear", "wolf", "fish",
+	"music", "guitar", "rock", "metal", "game", "player", "gamer",
+	"matrix", "ninja", "pokemon", "mustang", "ferrari", "porsche",
+	"corvette", "harley", "yamaha", "chelsea", "arsenal", "liverpool",
+	"united", "city", "london", "paris", "berlin", "madrid", "america",
+	"canada", "mexico", "brazil", "china", "india", "japan", "korea",
+	"test", "guest", "user", "root", "default", "changeme", "system",
+	"server", "internet", "google", "yahoo", "facebook", "twitter",
+	"windows", "linux", "microsoft", "oracle", "cisco", "zxcvbn",
+	"asdf", "azerty", "qwertz", "heapoverflow", "forum", "question",
+	"answer", "stack", "overflow", "code", "coder", "hacker", "hack",
+	"monday", "friday", "sunday", "january", "june", "july", "december",
+	"spider", "dragonfly", "butterfly", "rainbow", "thunder", "lightning",
+	"storm", "fire", "water", "earth", "star", "moon", "sun", "sky",
+	"blue", "red", "green", "black", "white", "pink", "magic", "wizard",
+	"knight", "king", "queen", "prince", "lady", "boss", "chief",
+	"captain", "doctor", "nurse", "teacher", "student", "school",
+	"college", "office", "work", "home", "house", "garden", "coffee",
+	"chocolate", "pizza", "burger", "beer", "wine", "vodka", "party",
+}
+
+// ranks is the position of every word of dictionary, from 1
+var ranks = func() map[string]int {
+	ranks := make(map[string]int, len(dictionary))
+	for i, word := range dictionary {
+		ranks[word] = i + 1
+	}
+	return ranks
+}()
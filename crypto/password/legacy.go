@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// the schemes of imported hashes
+func init() {
+	Register("argon2i", HasherFunc(compareArgon2i))
+	for _, scheme := range []string{"2a", "2b", "2y"} {
+		Register(scheme, HasherFunc(bcrypt.CompareHashAndPassword))
+	}
+	Register("scrypt", HasherFunc(compareScrypt))
+}
+
+var errFormat = errors.New("malformed hash")
+
+// phc splits a PHC string $id$v=19$params$salt$hash, without the version
+// segment when it is missing, into its params, salt and hash
+func phc(hashed []byte, version bool) (map[string]uint64, string, string,
+	error) {
+
+	parts := strings.Split(string(hashed), "$")
+	if version {
+		if len(parts) != 6 || parts[2] != "v=19" {
+			return nil, "", "", errFormat
+		}
+		parts = append(parts[:2], parts[3:]...)
+	}
+	if len(parts) != 5 {
+		return nil, "", "", errFormat
+	}
+
+	params := map[string]uint64{}
+	for _, param := range strings.Split(parts[2], ",") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, "", "", errFormat
+		}
+		value, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, "", "", errFormat
+		}
+		params[kv[0]] = value
+	}
+	return params, parts[3], parts[4], nil
+}
+
+// compareArgon2i verifies the $argon2i$v=19$m=,t=,p=$salt$hash PHC strings
+// of the reference implementation
+func compareArgon2i(hashed, password []byte) error {
+	params, rsalt, rkey, err := phc(hashed, true)
+	if err != nil {
+		return err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(rsalt)
+	if err != nil {
+		return errFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(rkey)
+	if err != nil {
+		return errFormat
+	}
+	if params["t"] == 0 || params["p"] == 0 || params["p"] > 255 {
+		return errFormat
+	}
+
+	derived := argon2.Key(password, salt, uint32(params["t"]),
+		uint32(params["m"]), uint8(params["p"]), uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// ab64 is the adapted base64 of passlib, . replaces +
+var ab64 = base64.NewEncoding(
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").
+	WithPadding(base64.NoPadding)
+
+// decode64 accepts the standard and the adapted base64 alphabets
+func decode64(s string) ([]byte, error) {
+	return ab64.DecodeString(strings.Replace(s, "+", ".", -1))
+}
+
+// compareScrypt verifies the $scrypt$ln=,r=,p=$salt$hash strings of passlib
+func compareScrypt(hashed, password []byte) error {
+	params, salt, rkey, err := phc(hashed, false)
+	if err != nil {
+		return err
+	}
+	key, err := decode64(rkey)
+	if err != nil {
+		return errFormat
+	}
+	if params["ln"] == 0 || params["ln"] > 30 {
+		return errFormat
+	}
+	rawSalt, err := decode64(salt)
+	if err != nil {
+		return errFormat
+	}
+
+	derived, err := scrypt.Key(password, rawSalt, 1<<params["ln"],
+		int(params["r"]), int(params["p"]), len(key))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package password hashes passwords with argon2id and verifies the hashes of
+// every registered scheme, so users imported with hashes of another scheme
+// can log in and get their hash upgraded.
+package password
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+)
+
+var (
+	ErrMismatch      = errors.New("hash and password mismatch")
+	ErrUnknownScheme = errors.New("unknown hash scheme")
+)
+
+// Hasher verifies the hashes of a scheme
+type Hasher interface {
+	Compare(hashed, password []byte) error
+}
+
+// HasherFunc adapts a function to the Hasher interface
+type HasherFunc func(hashed, password []byte) error
+
+func (f HasherFunc) Compare(hashed, password []byte) error {
+	return f(hashed, password)
+}
+
+// current is the scheme of new hashes
+const current = "argon2id"
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Hasher{
+		current: HasherFunc(argon2.CompareHashAndPassword),
+	}
+)
+
+// Register makes the hashes of scheme verifiable by h. The scheme is the
+// identifier between the first two $ of PHC and modular crypt hashes, such as
+// argon2i or 2b.
+func Register(scheme string, h Hasher) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[scheme] = h
+}
+
+// Scheme returns the identifier of the scheme of hashed
+func Scheme(hashed []byte) string {
+	s := string(hashed)
+	if !strings.HasPrefix(s, "$") {
+		return ""
+	}
+	end := strings.Index(s[1:], "$")
+	if end < 0 {
+		return ""
+	}
+	return s[1 : end+1]
+}
+
+// Hash returns the argon2id hash of password with the current policy
+func Hash(password []byte) (string, error) {
+	return argon2.GenerateFromPassword(password, nil, nil)
+}
+
+// Compare verifies password against hashed with the hasher of its scheme
+func Compare(hashed, password []byte) error {
+	mu.RLock()
+	h, found := registry[Scheme(hashed)]
+	mu.RUnlock()
+	if !found {
+		return ErrUnknownScheme
+	}
+	if err := h.Compare(hashed, password); err != nil {
+		return errors.Wrap(ErrMismatch, err.Error())
+	}
+	return nil
+}
+
+// dummy is a hash compared when a login names no user, so unknown accounts
+// answer as slowly as known ones
+type dummy struct {
+	once   sync.Once
+	hashed []byte
+}
+
+// dummies holds a dummy hash by argon2 parameters, each made on first use
+var dummies sync.Map
+
+// CompareDummy spends the time of verifying password without a user to
+// verify it for, against a dummy hash of the current argon2 policy and
+// pepper so it costs as much as a stored hash.
+func CompareDummy(password []byte) {
+	found, _ := dummies.LoadOrStore(argon2.Parameters(), &dummy{})
+	d := found.(*dummy)
+	d.once.Do(func() {
+		hashed, err := Hash([]byte("dummy password"))
+		if err == nil {
+			d.hashed = []byte(hashed)
+		}
+	})
+
+	Compare(d.hashed, password)
+}
+
+// NeedsRehash tells whether hashed is not an argon2id hash of the current
+// policy, such hashes are replaced on login
+func NeedsRehash(hashed []byte) bool {
+	return Scheme(hashed) != current || argon2.NeedsRehash(hashed, nil)
+}
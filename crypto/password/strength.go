@@ -0,0 +1,257 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Feedback codes of Estimate, Messages explains them to users
+const (
+	FeedbackDictionary = "dictionary_word"
+	FeedbackUserInput  = "user_input"
+	FeedbackKeyboard   = "keyboard_pattern"
+	FeedbackSequence   = "sequence"
+	FeedbackRepeat     = "repeat"
+	FeedbackDate       = "date"
+)
+
+var Messages = map[string]string{
+	FeedbackDictionary: "common words and passwords are easy to guess",
+	FeedbackUserInput:  "avoid your nick and e-mail",
+	FeedbackKeyboard:   "keyboard patterns like qwerty are easy to guess",
+	FeedbackSequence:   "sequences like abc or 123 are easy to guess",
+	FeedbackRepeat:     "repeated characters are easy to guess",
+	FeedbackDate:       "years and dates are easy to guess",
+}
+
+// Estimate is the strength of a password. Guesses is the log10 of the number
+// of guesses an attacker trying patterns first needs, Score ranks it from 0,
+// guessable in a few tries, to 4, out of reach of offline attacks.
+// Feedback lists the codes of the patterns found.
+type Estimate struct {
+	Guesses  float64  `json:"guesses"`
+	Score    int      `json:"score"`
+	Feedback []string `json:"feedback,omitempty"`
+}
+
+// scores are the log10 guesses of scores 1 to 4
+var scores = []float64{3, 6, 8, 10}
+
+// match is a pattern found between runes start and end of a password
+type match struct {
+	start, end int
+	guesses    float64
+	feedback   string
+}
+
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl",
+	"zxcvbnm", "azertyuiop", "qwertzuiop"}
+
+// keyboardKeys is the number of keys a keyboard pattern can start from
+const keyboardKeys = 47
+
+// leet undoes the common letter substitutions before dictionary lookups
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i",
+	"!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+// Strength estimates how hard pass is to guess, inputs are the words an
+// attacker knows of its user such as its nick and e-mail
+func Strength(pass string, inputs ...string) Estimate {
+	runes := []rune(pass)
+	lower := []rune(strings.ToLower(pass))
+
+	matches := dictionaryMatches(runes, lower, inputs)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, repeatMatches(lower)...)
+	matches = append(matches, dateMatches(lower)...)
+
+	// the cheapest cover of the password by patterns and single characters
+	best := make([]float64, len(runes)+1)
+	from := make([]*match, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		best[i] = best[i-1] + math.Log10(cardinality(runes[i-1]))
+		for j := range matches {
+			m := &matches[j]
+			if m.end != i {
+				continue
+			}
+			if guesses := best[m.start] + math.Log10(m.guesses); guesses < best[i] {
+				best[i] = guesses
+				from[i] = m
+			}
+		}
+	}
+
+	e := Estimate{Guesses: best[len(runes)]}
+	for _, threshold := range scores {
+		if e.Guesses >= threshold {
+			e.Score++
+		}
+	}
+	seen := map[string]bool{}
+	for i := len(runes); i > 0; {
+		m := from[i]
+		if m == nil {
+			i--
+			continue
+		}
+		if !seen[m.feedback] {
+			seen[m.feedback] = true
+			e.Feedback = append(e.Feedback, m.feedback)
+		}
+		i = m.start
+	}
+	return e
+}
+
+// cardinality is the number of characters of the class of r
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return 26
+	}
+	return 33
+}
+
+// dictionaryMatches finds common words and the words of inputs, lower cased
+// and with substitutions undone
+func dictionaryMatches(runes, lower []rune, inputs []string) []match {
+	known := map[string]bool{}
+	for _, input := range inputs {
+		for _, word := range strings.FieldsFunc(strings.ToLower(input),
+			func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			}) {
+			if len(word) >= 3 {
+				known[word] = true
+			}
+		}
+	}
+
+	found := []match{}
+	for i := range lower {
+		for j := i + 3; j <= len(lower); j++ {
+			raw := string(lower[i:j])
+			word := raw
+			if _, ok := ranks[word]; !ok && !known[word] {
+				word = leet.Replace(raw)
+			}
+			rank, code := 1, FeedbackUserInput
+			if !known[word] {
+				var ok bool
+				if rank, ok = ranks[word]; !ok {
+					continue
+				}
+				code = FeedbackDictionary
+			}
+			guesses := float64(rank)
+			if word != raw {
+				guesses *= 2
+			}
+			if string(runes[i:j]) != raw {
+				guesses *= 2
+			}
+			found = append(found, match{i, j, guesses, code})
+		}
+	}
+	return found
+}
+
+// sequenceMatches finds runs of consecutive letters or digits either way
+func sequenceMatches(lower []rune) []match {
+	found := []match{}
+	for i := 0; i < len(lower)-2; {
+		delta := lower[i+1] - lower[i]
+		if delta != 1 && delta != -1 {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(lower) && lower[j]-lower[j-1] == delta &&
+			sameClass(lower[j], lower[i]) {
+			j++
+		}
+		if j-i >= 3 {
+			base := 26.0
+			if unicode.IsDigit(lower[i]) {
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			found = append(found, match{i, j, base * float64(j-i),
+				FeedbackSequence})
+		}
+		i = j - 1
+	}
+	return found
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsDigit(a) == unicode.IsDigit(b) &&
+		unicode.IsLetter(a) == unicode.IsLetter(b)
+}
+
+// keyboardMatches finds runs of three keys or more along a keyboard row
+func keyboardMatches(lower []rune) []match {
+	found := []match{}
+	for _, row := range keyboardRows {
+		reversed := reverse(row)
+		for i := range lower {
+			for j := len(lower); j >= i+3; j-- {
+				run := string(lower[i:j])
+				if strings.Contains(row, run) ||
+					strings.Contains(reversed, run) {
+					found = append(found, match{i, j,
+						keyboardKeys * float64(j-i) * 2, FeedbackKeyboard})
+					break
+				}
+			}
+		}
+	}
+	return found
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// repeatMatches finds runs of three identical characters or more
+func repeatMatches(lower []rune) []match {
+	found := []match{}
+	for i := 0; i < len(lower); {
+		j := i + 1
+		for j < len(lower) && lower[j] == lower[i] {
+			j++
+		}
+		if j-i >= 3 {
+			found = append(found, match{i, j,
+				cardinality(lower[i]) * float64(j-i), FeedbackRepeat})
+		}
+		i = j
+	}
+	return found
+}
+
+// dateMatches finds the years from 1900 to 2099
+func dateMatches(lower []rune) []match {
+	found := []match{}
+	for i := 0; i+4 <= len(lower); i++ {
+		year := string(lower[i : i+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+			strings.IndexFunc(year, func(r rune) bool {
+				return !unicode.IsDigit(r)
+			}) < 0 {
+			found = append(found, match{i, i + 4, 200, FeedbackDate})
+		}
+	}
+	return found
+}
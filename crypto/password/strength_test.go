@@ -0,0 +1,71 @@
+package password
+
+import "testing"
+
+func hasFeedback(e Estimate, code string) bool {
+	for _, found := range e.Feedback {
+		if found == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TestStrengthUserInputs checks that the nick and e-mail of a user are
+// guessed first, leet substitutions included
+func TestStrengthUserInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		pass   string
+		inputs []string
+	}{
+		{"nick", "Zyxquorble!Mandrake", []string{"zyxquorble", "z@x.io"}},
+		{"e-mail words", "Zyxq-Tremolo-Vandyke",
+			[]string{"someone", "tremolo.vandyke@example.com"}},
+		{"leet nick", "5p4rr0wGl1nt!", []string{"sparrow", "a@b.co"}},
+	}
+	for _, test := range tests {
+		without := Strength(test.pass)
+		with := Strength(test.pass, test.inputs...)
+		if hasFeedback(without, FeedbackUserInput) {
+			t.Errorf("%s: %v without inputs", test.name, without.Feedback)
+		}
+		if !hasFeedback(with, FeedbackUserInput) {
+			t.Errorf("%s: feedback %v, want %s", test.name, with.Feedback,
+				FeedbackUserInput)
+		}
+		if with.Guesses >= without.Guesses {
+			t.Errorf("%s: %.1f guesses with inputs, %.1f without",
+				test.name, with.Guesses, without.Guesses)
+		}
+	}
+}
+
+func TestStrengthPatterns(t *testing.T) {
+	tests := []struct {
+		pass     string
+		feedback string
+		maxScore int
+	}{
+		{"password", FeedbackDictionary, 0},
+		{"qwertyuiop", FeedbackKeyboard, 0},
+		{"abcdefgh", FeedbackSequence, 0},
+		{"aaaaaaaa", FeedbackRepeat, 0},
+		{"alice1982", FeedbackDate, 3},
+	}
+	for _, test := range tests {
+		e := Strength(test.pass)
+		if !hasFeedback(e, test.feedback) {
+			t.Errorf("%s: feedback %v, want %s", test.pass, e.Feedback,
+				test.feedback)
+		}
+		if e.Score > test.maxScore {
+			t.Errorf("%s: score %d, want at most %d", test.pass, e.Score,
+				test.maxScore)
+		}
+	}
+
+	if e := Strength("vT9#qL2!xR7@mK4$"); e.Score != 4 || len(e.Feedback) != 0 {
+		t.Errorf("random password: score %d, feedback %v", e.Score, e.Feedback)
+	}
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is "12345678901234567890", the SHA-1 seed of the RFC 6238 test
+// vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 appendix B vectors have 8 digits, the codes here are their
+// last 6
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", test.unix, err)
+		}
+		if code != test.code {
+			t.Errorf("Code(%d) = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+	code, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil || code != "287082" {
+		t.Errorf("Code = %s, %v, want 287082", code, err)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted a secret that is not base32")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	tests := []struct {
+		name string
+		code string
+		skew int
+		step int64
+		ok   bool
+	}{
+		{"current step", "050471", 0, step, true},
+		{"previous step within skew", "081804", 1, step - 1, true},
+		{"previous step without skew", "081804", 0, 0, false},
+		{"wrong code", "123456", 1, 0, false},
+		{"8 digits", "14050471", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := Validate(rfcSecret, test.code, now, test.skew)
+		if ok != test.ok || got != test.step {
+			t.Errorf("%s: Validate = %d, %t, want %d, %t", test.name, got,
+				ok, test.step, test.ok)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+var (
+	errInvalidBody  = errors.New("Invalid body content")
+	errMissingParam = errors.New("Missing parameter")
+	errInvalidParam = errors.New("Invalid parameter")
+	errSelfVote     = errors.New("Cannot vote yourself")
+	errNotAccepted  = errors.New("Answer is not accepted")
+	errMissingToken = errors.New("Missing JWT")
+	errInvalidToken = errors.New("Invalid JWT")
+	errRateLimited  = errors.New("Rate limiting")
+	errLoginLocked  = errors.New("Too many failed logins")
+
+	errInvalidRefresh = errors.New("Invalid refresh token")
+	errRefreshReused  = errors.New("Refresh token reused, session revoked")
+
+	errInvalidCode      = errors.New("Invalid two-factor code")
+	errInvalidChallenge = errors.New("Invalid two-factor challenge")
+	errTwoFactorEnabled = errors.New("Two-factor authentication already enabled")
+
+	errInvalidResetToken  = errors.New("Invalid password reset token")
+	errInvalidVerifyToken = errors.New("Invalid e-mail verification token")
+	errUnverified         = errors.New("E-mail not verified")
+)
+
+// problem is an RFC 7807 problem detail, Code is stable for clients to match
+// on, Error repeats Detail for clients reading the response envelope and
+// Fields lists the failed rules of an invalid body
+type problem struct {
+	Type   string             `json:"type"`
+	Title  string             `json:"title"`
+	Status int                `json:"status"`
+	Detail string             `json:"detail,omitempty"`
+	Code   string             `json:"code"`
+	Error  string             `json:"error"`
+	Fields []model.FieldError `json:"fields,omitempty"`
+}
+
+type kind struct {
+	status int
+	code   string
+}
+
+// kinds maps the cause of handler errors to their status and code, any other
+// cause is an internal error
+var kinds = map[error]kind{
+	storage.ErrUserNotFound:     {http.StatusNotFound, "user_not_found"},
+	storage.ErrQuestionNotFound: {http.StatusNotFound, "question_not_found"},
+	storage.ErrAnswerNotFound:   {http.StatusNotFound, "answer_not_found"},
+	storage.ErrCommentNotFound:  {http.StatusNotFound, "comment_not_found"},
+	storage.ErrVoteNotFound:     {http.StatusNotFound, "vote_not_found"},
+	storage.ErrTagNotFound:      {http.StatusNotFound, "tag_not_found"},
+	storage.ErrRevisionNotFound: {http.StatusNotFound, "revision_not_found"},
+	storage.ErrSessionNotFound:  {http.StatusNotFound, "session_not_found"},
+	storage.ErrAPITokenNotFound: {http.StatusNotFound, "api_token_not_found"},
+	storage.ErrTwoFactorNotFound: {http.StatusNotFound,
+		"two_factor_not_found"},
+	storage.ErrQuestionAlreadyExist: {http.StatusConflict,
+		"question_already_exist"},
+	storage.ErrUserAlreadyExist:   {http.StatusConflict, "user_already_exist"},
+	storage.ErrInvalidCredentials: {http.StatusUnauthorized, "invalid_credentials"},
+	storage.ErrInvalidQuery:       {http.StatusBadRequest, "invalid_query"},
+	storage.ErrEmptySearch:        {http.StatusBadRequest, "empty_search"},
+
+	model.ErrInvalidUser:     {http.StatusUnprocessableEntity, "invalid_user"},
+	model.ErrInvalidEmail:    {http.StatusUnprocessableEntity, "invalid_email"},
+	model.ErrInvalidQuestion: {http.StatusUnprocessableEntity, "invalid_question"},
+	model.ErrInvalidAnswer:   {http.StatusUnprocessableEntity, "invalid_answer"},
+	model.ErrInvalidComment:  {http.StatusUnprocessableEntity, "invalid_comment"},
+	model.ErrInvalidVote:     {http.StatusUnprocessableEntity, "invalid_vote"},
+	model.ErrInvalidTag:      {http.StatusUnprocessableEntity, "invalid_tag"},
+	model.ErrInvalidRevision: {http.StatusUnprocessableEntity, "invalid_revision"},
+	model.ErrInvalidRole:     {http.StatusUnprocessableEntity, "invalid_role"},
+	model.ErrForbidden:       {http.StatusForbidden, "forbidden"},
+	model.ErrInvalidAPIToken: {http.StatusUnprocessableEntity,
+		"invalid_api_token"},
+
+	errInvalidBody:  {http.StatusBadRequest, "invalid_body"},
+	errMissingParam: {http.StatusBadRequest, "missing_parameter"},
+	errInvalidParam: {http.StatusBadRequest, "invalid_parameter"},
+	errSelfVote:     {http.StatusForbidden, "self_vote"},
+	errNotAccepted:  {http.StatusConflict, "not_accepted"},
+	errMissingToken: {http.StatusUnauthorized, "missing_token"},
+	errInvalidToken: {http.StatusUnauthorized, "invalid_token"},
+	errRateLimited:  {http.StatusTooManyRequests, "rate_limited"},
+	errLoginLocked:  {http.StatusTooManyRequests, "login_locked"},
+
+	errInvalidRefresh: {http.StatusUnauthorized, "invalid_refresh_token"},
+	errRefreshReused:  {http.StatusUnauthorized, "refresh_token_reused"},
+
+	errInvalidCode:      {http.StatusUnauthorized, "invalid_code"},
+	errInvalidChallenge: {http.StatusUnauthorized, "invalid_challenge"},
+	errTwoFactorEnabled: {http.StatusConflict, "two_factor_enabled"},
+
+	errInvalidResetToken:  {http.StatusBadRequest, "invalid_reset_token"},
+	errInvalidVerifyToken: {http.StatusBadRequest, "invalid_verification_token"},
+	errUnverified:         {http.StatusForbidden, "email_not_verified"},
+}
+
+// problemOf builds the problem answered for err, internal errors keep their
+// detail out of the response
+func problemOf(err error) problem {
+	k, found := kinds[errors.Cause(err)]
+	if !found {
+		k = kind{http.StatusInternalServerError, "internal_error"}
+	}
+
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(k.status),
+		Status: k.status,
+		Code:   k.code,
+	}
+	if found {
+		p.Detail = err.Error()
+		var invalid *model.ValidationError
+		if errors.As(err, &invalid) {
+			p.Fields = invalid.Fields
+		}
+	}
+	p.Error = p.Title
+	if p.Detail != "" {
+		p.Error = p.Detail
+	}
+	return p
+}
+
+func writeProblem(w http.ResponseWriter, err error) problem {
+	p := problemOf(err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+	return p
+}
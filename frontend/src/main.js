@@ -1,6 +1,7 @@
 import '@babel/polyfill'
 import Vue from 'vue'
 import './plugins/bootstrap-vue'
+import './assets/highlight.css'
 import App from './App.vue'
 import router from './router'
 
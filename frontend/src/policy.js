@@ -0,0 +1,17 @@
+// the validation limits of the server, fetched once and shared by the forms
+let policy = null;
+
+const loadPolicy = api => {
+  if (!policy) {
+    policy = fetch(api + "/policy", { mode: "cors" })
+      .then(resp => resp.json())
+      .then(r => r.result)
+      .catch(() => {
+        policy = null;
+        return null;
+      });
+  }
+  return policy;
+};
+
+export default loadPolicy;
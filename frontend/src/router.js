@@ -9,6 +9,9 @@ import CreateUser from "./components/CreateUser.vue";
 import CreateComment from "./components/CreateComment.vue";
 import EditComment from "./components/EditComment.vue";
 import Profile from "./components/Profile.vue";
+import ForgotPassword from "./components/ForgotPassword.vue";
+import ResetPassword from "./components/ResetPassword.vue";
+import VerifyEmail from "./components/VerifyEmail.vue";
 import isLogged from "./auth";
 
 Vue.use(Router);
@@ -33,6 +36,24 @@ const router = new Router({
       component: CreateUser,
       meta: { public: true }
     },
+    {
+      path: "/forgot",
+      name: "ForgotPassword",
+      component: ForgotPassword,
+      meta: { public: true }
+    },
+    {
+      path: "/reset",
+      name: "ResetPassword",
+      component: ResetPassword,
+      meta: { public: true }
+    },
+    {
+      path: "/verify",
+      name: "VerifyEmail",
+      component: VerifyEmail,
+      meta: { public: true }
+    },
     {
       path: "/logout",
       name: "Logout",
@@ -6,3 +6,16 @@ import (
 
 type appHandler func(w http.ResponseWriter, r *http.Request) (interface{},
 	error)
+
+// paged is returned by handlers of paginated listings, the logger moves next
+// to the response envelope
+type paged struct {
+	result interface{}
+	next   string
+}
+
+// bare is returned by handlers answering a standard document, the logger
+// writes it as is without the response envelope
+type bare struct {
+	document interface{}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 func jsonFromRequest(dst interface{}, r *http.Request) error {
@@ -18,11 +19,11 @@ func jsonFromRequest(dst interface{}, r *http.Request) error {
 		return err
 	}
 	if !json.Valid(body) {
-		return errors.Errorf("Invalid body content")
+		return errInvalidBody
 	}
 
 	if err := json.Unmarshal(body, &dst); err != nil {
-		return errors.Wrap(err, "cannot unmarshal json body")
+		return errors.Wrapf(errInvalidBody, "cannot unmarshal json body: %s", err)
 	}
 	return nil
 }
@@ -31,16 +32,45 @@ func idFromRequest(param string, r *http.Request) (int, error) {
 	params := mux.Vars(r)
 	rawID, exist := params[param]
 	if !exist {
-		return -1, errors.Errorf("Missing %s parameter", param)
+		return -1, errors.Wrap(errMissingParam, param)
 	}
 
 	id, err := strconv.Atoi(rawID)
 	if err != nil {
-		return -1, errors.Wrapf(err, "cannot convert %d to int", id)
+		return -1, errors.Wrapf(errInvalidParam, "cannot convert %s to int",
+			rawID)
 	}
 	return id, nil
 }
 
+// queryFromRequest reads limit, cursor, sort, author and tag listing
+// parameters from the URL query
+func queryFromRequest(r *http.Request) (storage.Query, error) {
+	values := r.URL.Query()
+	query := storage.Query{
+		Cursor: values.Get("cursor"),
+		Sort:   values.Get("sort"),
+		Tags:   values["tag"],
+	}
+
+	for param, dst := range map[string]*int{
+		"limit":  &query.Limit,
+		"author": &query.Author,
+	} {
+		raw := values.Get(param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return storage.Query{}, errors.Wrap(errInvalidParam, param)
+		}
+		*dst = value
+	}
+
+	return query, nil
+}
+
 func staticWhiteList(root, path string) bool {
 	if _, err := os.Stat(filepath.Join(root, path)); err != nil {
 		return false
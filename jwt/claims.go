@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLeeway is the clock skew tolerated between the issuer and verifiers
+const DefaultLeeway = time.Minute
+
+var (
+	ErrExpired         = errors.New("Token expired")
+	ErrMissingExp      = errors.New("Token without expiration")
+	ErrNotYetValid     = errors.New("Token not yet valid")
+	ErrIssuedInFuture  = errors.New("Token issued in the future")
+	ErrInvalidIssuer   = errors.New("Invalid token issuer")
+	ErrInvalidAudience = errors.New("Invalid token audience")
+)
+
+// Payload holds the registered claims of RFC 7519, the claims of the
+// application and any other claim in Custom. Sub is the user id, Email is
+// only informative as it can change.
+type Payload struct {
+	Iss   string   `json:"iss,omitempty"`
+	Sub   string   `json:"sub,omitempty"`
+	Aud   Audience `json:"aud,omitempty"`
+	Exp   int64    `json:"exp,omitempty"`
+	Nbf   int64    `json:"nbf,omitempty"`
+	Iat   int64    `json:"iat,omitempty"`
+	Jti   string   `json:"jti,omitempty"`
+	Email string   `json:"email,omitempty"`
+	Role  string   `json:"role,omitempty"`
+	Sid   string   `json:"sid,omitempty"`
+
+	Custom map[string]interface{} `json:"-"`
+}
+
+// payload has the fields of Payload without its json methods
+type payload Payload
+
+func (p Payload) MarshalJSON() ([]byte, error) {
+	known, err := json.Marshal(payload(p))
+	if err != nil || len(p.Custom) == 0 {
+		return known, err
+	}
+
+	claims := map[string]interface{}{}
+	for name, value := range p.Custom {
+		claims[name] = value
+	}
+	// registered and application claims win over custom ones of the same name
+	if err := json.Unmarshal(known, &claims); err != nil {
+		return nil, err
+	}
+	return json.Marshal(claims)
+}
+
+func (p *Payload) UnmarshalJSON(raw []byte) error {
+	var known payload
+	if err := json.Unmarshal(raw, &known); err != nil {
+		return err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return err
+	}
+	for _, name := range []string{"iss", "sub", "aud", "exp", "nbf", "iat",
+		"jti", "email", "role", "sid"} {
+		delete(claims, name)
+	}
+	if len(claims) > 0 {
+		known.Custom = claims
+	}
+
+	*p = Payload(known)
+	return nil
+}
+
+// Audience is the aud claim, a single audience is encoded as a string
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(raw []byte) error {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return err
+	}
+	*a = Audience(many)
+	return nil
+}
+
+// Contains tells whether aud is one of the audiences
+func (a Audience) Contains(aud string) bool {
+	for _, candidate := range a {
+		if candidate == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator checks the registered claims of a payload. A token without exp
+// never expires and is rejected. Empty Issuer or Audience accept any value,
+// Leeway is the clock skew tolerated on time claims and Now defaults to
+// time.Now.
+type Validator struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	Now      func() time.Time
+}
+
+func (v Validator) Validate(p Payload) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if p.Exp == 0 {
+		return ErrMissingExp
+	}
+	if !now.Before(time.Unix(p.Exp, 0).Add(v.Leeway)) {
+		return ErrExpired
+	}
+	if p.Nbf != 0 && now.Add(v.Leeway).Before(time.Unix(p.Nbf, 0)) {
+		return ErrNotYetValid
+	}
+	if p.Iat != 0 && now.Add(v.Leeway).Before(time.Unix(p.Iat, 0)) {
+		return ErrIssuedInFuture
+	}
+	if v.Issuer != "" && p.Iss != v.Issuer {
+		return errors.Wrap(ErrInvalidIssuer, p.Iss)
+	}
+	if v.Audience != "" && !p.Aud.Contains(v.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	exp := now.Add(time.Minute).Unix()
+	v := Validator{Issuer: "iss", Audience: "aud", Leeway: time.Minute,
+		Now: func() time.Time { return now }}
+
+	tests := []struct {
+		name string
+		p    Payload
+		err  error
+	}{
+		{"valid", Payload{Iss: "iss", Aud: Audience{"aud"}, Exp: exp}, nil},
+		{"no exp", Payload{Iss: "iss", Aud: Audience{"aud"}}, ErrMissingExp},
+		{"expired beyond leeway", Payload{Iss: "iss", Aud: Audience{"aud"},
+			Exp: now.Add(-time.Minute).Unix()}, ErrExpired},
+		{"expired within leeway", Payload{Iss: "iss", Aud: Audience{"aud"},
+			Exp: now.Add(-time.Second).Unix()}, nil},
+		{"not yet valid", Payload{Iss: "iss", Aud: Audience{"aud"}, Exp: exp,
+			Nbf: now.Add(2 * time.Minute).Unix()}, ErrNotYetValid},
+		{"issued in the future", Payload{Iss: "iss", Aud: Audience{"aud"},
+			Exp: exp, Iat: now.Add(2 * time.Minute).Unix()}, ErrIssuedInFuture},
+		{"other issuer", Payload{Iss: "other", Aud: Audience{"aud"}, Exp: exp},
+			ErrInvalidIssuer},
+		{"other audience", Payload{Iss: "iss", Aud: Audience{"x", "y"},
+			Exp: exp}, ErrInvalidAudience},
+	}
+	for _, test := range tests {
+		if err := v.Validate(test.p); errors.Cause(err) != test.err {
+			t.Errorf("%s: Validate = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
@@ -1,12 +1,8 @@
 package jwt
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 
@@ -14,37 +10,23 @@ import (
 )
 
 var (
-	defaultHeader     = joseHeader{Typ: "JWT", Alg: "HS256"}
-	DefaultExpiration = time.Now().AddDate(0, 0, 7).Unix()
-	defaultLeeway     = 10 * time.Minute
+	// DefaultExpiration is the lifetime of access tokens, sessions renew
+	// them with refresh tokens
+	DefaultExpiration = 15 * time.Minute
 )
 
 type Token struct {
 	header    joseHeader
 	payload   Payload
+	signed    string
 	signature []byte
-	key       []byte
+	keys      *KeySet
 }
 
 type joseHeader struct {
 	Typ string `json:"typ,omitempty"`
 	Alg string `json:"alg,omitempty"`
-}
-
-type Payload struct {
-	Exp   int64  `json:"exp,omitempty"`
-	Nbf   int64  `json:"nbf,omitempty"`
-	Email string `json:"email,omitempty"`
-}
-
-func (p Payload) ValidNbf() bool {
-	nbf := time.Unix(p.Nbf, 0)
-	return nbf.Before(time.Now())
-}
-
-func (p Payload) ValidExp() bool {
-	exp := time.Unix(p.Exp, 0)
-	return time.Now().Before(exp)
+	Kid string `json:"kid,omitempty"`
 }
 
 func (p Payload) String() (string, error) {
@@ -55,27 +37,32 @@ func (h joseHeader) String() (string, error) {
 	return b64EncodeMarshal(h)
 }
 
-func NewFromFile(claims Payload, keyfile string) *Token {
-	claims.Nbf = time.Now().Add(-defaultLeeway).Unix()
-	token := &Token{
-		header:  defaultHeader,
+// New returns a token of claims signed or checked with keys, iat and nbf
+// default to now
+func New(claims Payload, keys *KeySet) *Token {
+	now := time.Now().Unix()
+	if claims.Iat == 0 {
+		claims.Iat = now
+	}
+	if claims.Nbf == 0 {
+		claims.Nbf = now
+	}
+	return &Token{
 		payload: claims,
+		keys:    keys,
 	}
-	token.loadKeyFromFile(keyfile)
-	return token
 }
 
-func (t *Token) loadKeyFromFile(keyfile string) error {
-	key, err := ioutil.ReadFile(keyfile)
-	if err != nil {
-		return err
-	}
-	t.key = make([]byte, len(key))
-	copy(t.key, key)
-	return nil
+// Claims returns the payload of a decoded token
+func (t Token) Claims() Payload {
+	return t.payload
 }
 
+// Encode signs the token with the signing key of its set
 func (t Token) Encode() (string, error) {
+	key := t.keys.signer()
+	t.header = joseHeader{Typ: "JWT", Alg: key.Alg(), Kid: key.ID}
+
 	b64Header, err := t.header.String()
 	if err != nil {
 		return "", err
@@ -87,8 +74,11 @@ func (t Token) Encode() (string, error) {
 	}
 
 	hp := b64Header + "." + b64Payload
-	t.sign(hp)
-	return hp + "." + base64.RawURLEncoding.EncodeToString(t.signature), nil
+	signature, err := key.Sign([]byte(hp))
+	if err != nil {
+		return "", err
+	}
+	return hp + "." + base64.RawURLEncoding.EncodeToString(signature), nil
 }
 
 func (t *Token) Decode(s string) error {
@@ -109,26 +99,12 @@ func (t *Token) Decode(s string) error {
 	}
 	t.signature = make([]byte, len(signature))
 	copy(t.signature, signature)
+	// the signature covers the received bytes, not a new encoding of them
+	t.signed = parts[0] + "." + parts[1]
 
 	return nil
 }
 
-func DecodePayload(r *http.Request) (payload Payload) {
-	header := r.Header.Get("Authorization")
-	if header == "" || !strings.HasPrefix(header, "Bearer ") {
-		return
-	}
-
-	rawToken := header[len("Bearer "):]
-	parts := strings.Split(rawToken, ".")
-	if len(parts) != 3 {
-		return
-	}
-
-	b64DecodeUnmarshal(parts[1], &payload)
-	return
-}
-
 func b64EncodeMarshal(src interface{}) (string, error) {
 	r, err := json.Marshal(src)
 	if err != nil {
@@ -148,44 +124,15 @@ func b64DecodeUnmarshal(s string, dst interface{}) error {
 	return nil
 }
 
-func (t Token) Check() error {
-	b64Header, err := t.header.String()
+// Check verifies the signature with the key named by the kid header, then the
+// claims with v
+func (t Token) Check(v Validator) error {
+	key, err := t.keys.verifier(t.header)
 	if err != nil {
 		return err
 	}
-
-	b64Payload, err := t.payload.String()
-	if err != nil {
+	if err := key.Verify([]byte(t.signed), t.signature); err != nil {
 		return err
 	}
-
-	if t.payload.Nbf != 0 {
-		if !t.payload.ValidNbf() {
-			return errors.Errorf("Invalid nbf claim")
-		}
-	}
-	if t.payload.Exp != 0 {
-		if !t.payload.ValidExp() {
-			return errors.Errorf("Invalid exp claim")
-		}
-	}
-
-	hp := b64Header + "." + b64Payload
-	signature := sign(hp, t.key)
-	if check := hmac.Equal(t.signature, signature); !check {
-		return errors.Errorf("Invalid signature")
-	}
-	return nil
-}
-
-func (t *Token) sign(s string) {
-	signature := sign(s, t.key)
-	t.signature = make([]byte, len(signature))
-	copy(t.signature, signature)
-}
-
-func sign(s string, key []byte) []byte {
-	mac := hmac.New(sha256.New, key)
-	mac.Write([]byte(s))
-	return mac.Sum(nil)
+	return v.Validate(t.payload)
 }
@@ -0,0 +1,287 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithms lists every algorithm the package implements, "none" is never
+// accepted
+var Algorithms = []string{"HS256", "RS256", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	ErrUnknownKey        = errors.New("Unknown key id")
+	ErrAlgNotAllowed     = errors.New("Algorithm not allowed")
+	ErrNoSigningKey      = errors.New("No signing key")
+	ErrUnsupportedKeyPEM = errors.New("Unsupported PEM key")
+	ErrUnknownKeyFile    = errors.New("Key file neither PEM nor .hmac")
+)
+
+// Key is a signer known by its key id, the kid header of the tokens it signs
+type Key struct {
+	ID string
+	Signer
+}
+
+// KeySet holds the keys tokens are signed and verified with. The signing key
+// is the last one by id able to sign, older keys keep verifying the tokens
+// they issued until they are removed. A token is only accepted when its alg
+// header is allowed and is the algorithm of the key named by its kid.
+type KeySet struct {
+	keys    map[string]Key
+	signing string
+	allowed map[string]bool
+}
+
+// NewKeySet returns the set of keys restricted to the algs allow list, every
+// implemented algorithm when algs is empty
+func NewKeySet(algs []string, keys ...Key) (*KeySet, error) {
+	if len(algs) == 0 {
+		algs = Algorithms
+	}
+	ks := &KeySet{keys: map[string]Key{}, allowed: map[string]bool{}}
+	for _, alg := range algs {
+		if !supported(alg) {
+			return nil, errors.Wrap(ErrAlgNotAllowed, alg)
+		}
+		ks.allowed[alg] = true
+	}
+
+	ids := []string{}
+	for _, key := range keys {
+		if !ks.allowed[key.Alg()] {
+			return nil, errors.Wrapf(ErrAlgNotAllowed, "key %s uses %s", key.ID,
+				key.Alg())
+		}
+		if _, found := ks.keys[key.ID]; found {
+			return nil, errors.Errorf("duplicated key id %s", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ids = append(ids, key.ID)
+	}
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		if ks.keys[id].CanSign() {
+			ks.signing = id
+		}
+	}
+	if ks.signing == "" {
+		return nil, ErrNoSigningKey
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads the keys of path, a directory of key files or a single
+// file. Key ids are the file names without extension. PEM files hold RSA,
+// ECDSA or Ed25519 private keys, or public keys to only verify in *.pub
+// files, *.hmac files are HS256 secrets and any other file is rejected.
+func LoadKeySet(path string, algs ...string) (*KeySet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load keys")
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load keys")
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	keys := []Key{}
+	for _, file := range files {
+		key, err := loadKey(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load key %s", file)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(algs, keys...)
+}
+
+func loadKey(file string) (Key, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Key{}, err
+	}
+	name, ext := filepath.Base(file), filepath.Ext(file)
+	key := Key{ID: strings.TrimSuffix(name, ext)}
+
+	if ext == ".hmac" {
+		key.Signer = NewHMAC(raw)
+		return key, nil
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return Key{}, ErrUnknownKeyFile
+	}
+
+	// public keys only verify so they are told apart by the file name
+	if public := block.Type == "PUBLIC KEY"; public != (ext == ".pub") {
+		return Key{}, errors.Wrapf(ErrUnsupportedKeyPEM, "%s in %s file",
+			block.Type, ext)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		key.Signer, err = NewRSA(&private.PublicKey, private)
+		return key, err
+	case "EC PRIVATE KEY":
+		private, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		key.Signer, err = NewECDSA(&private.PublicKey, private)
+		return key, err
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		key.Signer, err = newSigner(private)
+		return key, err
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		key.Signer, err = newSigner(public)
+		return key, err
+	}
+	return Key{}, errors.Wrap(ErrUnsupportedKeyPEM, block.Type)
+}
+
+// newSigner returns the signer of a parsed private or public key
+func newSigner(k interface{}) (Signer, error) {
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		return NewRSA(&k.PublicKey, k)
+	case *rsa.PublicKey:
+		return NewRSA(k, nil)
+	case *ecdsa.PrivateKey:
+		return NewECDSA(&k.PublicKey, k)
+	case *ecdsa.PublicKey:
+		return NewECDSA(k, nil)
+	case ed25519.PrivateKey:
+		return NewEd25519(k.Public().(ed25519.PublicKey), k), nil
+	case ed25519.PublicKey:
+		return NewEd25519(k, nil), nil
+	}
+	return nil, errors.Errorf("unsupported key type %T", k)
+}
+
+func supported(alg string) bool {
+	for _, known := range Algorithms {
+		if alg == known {
+			return true
+		}
+	}
+	return false
+}
+
+// signer returns the key new tokens are signed with
+func (ks *KeySet) signer() Key {
+	return ks.keys[ks.signing]
+}
+
+// verifier returns the key of a token header, the header algorithm must be
+// allowed and be the one of the key so a token cannot pick how it is checked
+func (ks *KeySet) verifier(h joseHeader) (Key, error) {
+	if !ks.allowed[h.Alg] {
+		return Key{}, errors.Wrap(ErrAlgNotAllowed, h.Alg)
+	}
+	key, found := ks.keys[h.Kid]
+	if !found {
+		return Key{}, errors.Wrap(ErrUnknownKey, h.Kid)
+	}
+	if key.Alg() != h.Alg {
+		return Key{}, errors.Wrapf(ErrAlgNotAllowed, "key %s uses %s", key.ID,
+			key.Alg())
+	}
+	return key, nil
+}
+
+// JWK is the public part of a key as published in a JWKS (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set sorted by id, shared secrets are
+// never published
+func (ks *KeySet) JWKS() JWKS {
+	ids := []string{}
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JWK{Use: "sig", Alg: key.Alg(), Kid: key.ID}
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(public.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			x, y := make([]byte, size), make([]byte, size)
+			public.X.FillBytes(x)
+			public.Y.FillBytes(y)
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X, jwk.Y = b64(x), b64(y)
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func b64(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func newEd25519(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+// forge encodes a token with any header, signed by key unless key is nil
+func forge(t *testing.T, h joseHeader, p Payload, key Signer) string {
+	b64Header, err := h.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64Payload, err := p.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64Header + "." + b64Payload
+	if key == nil {
+		return signed + "."
+	}
+	signature, err := key.Sign([]byte(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestCheck(t *testing.T) {
+	public, private := newEd25519(t)
+	secret := NewHMAC([]byte("shared secret"))
+	ed := NewEd25519(public, private)
+	keys, err := NewKeySet(nil, Key{"a-hs", secret}, Key{"b-ed", ed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edOnly, err := NewKeySet([]string{"EdDSA"}, Key{"b-ed", ed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := Payload{Sub: "1", Exp: time.Now().Add(time.Minute).Unix()}
+	signed, err := New(claims, keys).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := forge(t, joseHeader{"JWT", "EdDSA", "b-ed"},
+		Payload{Sub: "2", Exp: claims.Exp}, nil) +
+		signed[len(signed)-86:]
+
+	tests := []struct {
+		name  string
+		keys  *KeySet
+		token string
+		err   error
+	}{
+		{"signed by the signing key", keys, signed, nil},
+		{"alg none", keys,
+			forge(t, joseHeader{"JWT", "none", "b-ed"}, claims, nil),
+			ErrAlgNotAllowed},
+		{"alg none without kid", keys,
+			forge(t, joseHeader{"JWT", "none", ""}, claims, nil),
+			ErrAlgNotAllowed},
+		{"HS256 with the kid of a public key", keys,
+			forge(t, joseHeader{"JWT", "HS256", "b-ed"}, claims,
+				NewHMAC(public)),
+			ErrAlgNotAllowed},
+		{"EdDSA with the kid of a secret", keys,
+			forge(t, joseHeader{"JWT", "EdDSA", "a-hs"}, claims, ed),
+			ErrAlgNotAllowed},
+		{"unknown kid", keys,
+			forge(t, joseHeader{"JWT", "EdDSA", "c-ed"}, claims, ed),
+			ErrUnknownKey},
+		{"alg outside the allow list", edOnly,
+			forge(t, joseHeader{"JWT", "HS256", "a-hs"}, claims, secret),
+			ErrAlgNotAllowed},
+		{"tampered payload", keys, tampered, ErrInvalidSignature},
+	}
+	for _, test := range tests {
+		token := New(Payload{}, test.keys)
+		if err := token.Decode(test.token); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := token.Check(Validator{}); errors.Cause(err) != test.err {
+			t.Errorf("%s: Check = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	public, private := newEd25519(t)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY",
+		Bytes: der})
+	der, err = x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY",
+		Bytes: der})
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		err   error
+	}{
+		{"secret", map[string][]byte{"a.hmac": []byte("secret")}, nil},
+		{"private key", map[string][]byte{"a.pem": privatePEM}, nil},
+		{"public key beside a secret", map[string][]byte{
+			"a.pub": publicPEM, "b.hmac": []byte("secret")}, nil},
+		{"public key alone", map[string][]byte{"a.pub": publicPEM},
+			ErrNoSigningKey},
+		{"secret without extension", map[string][]byte{
+			"a.key": []byte("secret")}, ErrUnknownKeyFile},
+		{"public key outside *.pub", map[string][]byte{"a.pem": publicPEM},
+			ErrUnsupportedKeyPEM},
+		{"private key in *.pub", map[string][]byte{"a.pub": privatePEM},
+			ErrUnsupportedKeyPEM},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, content := range test.files {
+			err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := LoadKeySet(dir)
+		if errors.Cause(err) != test.err {
+			t.Errorf("%s: LoadKeySet = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
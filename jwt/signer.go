@@ -0,0 +1,201 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+const minRSABits = 2048
+
+var (
+	ErrInvalidSignature = errors.New("Invalid signature")
+	ErrVerifyOnly       = errors.New("Key can only verify")
+)
+
+// Signer signs and verifies tokens with a single algorithm, Public is the
+// key published in the JWKS and is nil for shared secrets
+type Signer interface {
+	Alg() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, signature []byte) error
+	Public() crypto.PublicKey
+	CanSign() bool
+}
+
+type hmacSigner struct {
+	secret []byte
+}
+
+// NewHMAC returns the HS256 signer of a shared secret
+func NewHMAC(secret []byte) Signer {
+	key := make([]byte, len(secret))
+	copy(key, secret)
+	return hmacSigner{key}
+}
+
+func (s hmacSigner) Alg() string              { return "HS256" }
+func (s hmacSigner) Public() crypto.PublicKey { return nil }
+func (s hmacSigner) CanSign() bool            { return true }
+
+func (s hmacSigner) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s hmacSigner) Verify(data, signature []byte) error {
+	expected, _ := s.Sign(data)
+	if !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type rsaSigner struct {
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+// NewRSA returns the RS256 signer of a key of at least 2048 bits, a nil
+// private key gives a signer that can only verify
+func NewRSA(public *rsa.PublicKey, private *rsa.PrivateKey) (Signer, error) {
+	if public.N.BitLen() < minRSABits {
+		return nil, errors.Errorf("RSA key must have at least %d bits",
+			minRSABits)
+	}
+	return rsaSigner{private, public}, nil
+}
+
+func (s rsaSigner) Alg() string              { return "RS256" }
+func (s rsaSigner) Public() crypto.PublicKey { return s.public }
+func (s rsaSigner) CanSign() bool            { return s.private != nil }
+
+func (s rsaSigner) Sign(data []byte) ([]byte, error) {
+	if s.private == nil {
+		return nil, ErrVerifyOnly
+	}
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, s.private, crypto.SHA256, digest[:])
+}
+
+func (s rsaSigner) Verify(data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	if err := rsa.VerifyPKCS1v15(s.public, crypto.SHA256, digest[:],
+		signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// curves maps the supported ECDSA curves to their JWS algorithm
+var curves = map[elliptic.Curve]struct {
+	alg  string
+	hash func() hash.Hash
+}{
+	elliptic.P256(): {"ES256", sha256.New},
+	elliptic.P384(): {"ES384", sha512.New384},
+	elliptic.P521(): {"ES512", sha512.New},
+}
+
+type ecdsaSigner struct {
+	private *ecdsa.PrivateKey
+	public  *ecdsa.PublicKey
+}
+
+// NewECDSA returns the ES256, ES384 or ES512 signer of a key on the P-256,
+// P-384 or P-521 curve, a nil private key gives a signer that can only verify
+func NewECDSA(public *ecdsa.PublicKey,
+	private *ecdsa.PrivateKey) (Signer, error) {
+
+	if _, found := curves[public.Curve]; !found {
+		return nil, errors.Errorf("unsupported curve %s",
+			public.Curve.Params().Name)
+	}
+	return ecdsaSigner{private, public}, nil
+}
+
+func (s ecdsaSigner) Alg() string              { return curves[s.public.Curve].alg }
+func (s ecdsaSigner) Public() crypto.PublicKey { return s.public }
+func (s ecdsaSigner) CanSign() bool            { return s.private != nil }
+
+func (s ecdsaSigner) digest(data []byte) []byte {
+	h := curves[s.public.Curve].hash()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// size is the length of r and s in a JWS signature
+func (s ecdsaSigner) size() int {
+	return (s.public.Curve.Params().BitSize + 7) / 8
+}
+
+// Sign answers r and s padded to the curve size as JWS requires, not ASN.1
+func (s ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	if s.private == nil {
+		return nil, ErrVerifyOnly
+	}
+	r, ss, err := ecdsa.Sign(rand.Reader, s.private, s.digest(data))
+	if err != nil {
+		return nil, err
+	}
+	size := s.size()
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	ss.FillBytes(signature[size:])
+	return signature, nil
+}
+
+func (s ecdsaSigner) Verify(data, signature []byte) error {
+	size := s.size()
+	if len(signature) != 2*size {
+		return ErrInvalidSignature
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	ss := new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(s.public, s.digest(data), r, ss) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ed25519Signer struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// NewEd25519 returns the EdDSA signer of a key, a nil private key gives a
+// signer that can only verify
+func NewEd25519(public ed25519.PublicKey,
+	private ed25519.PrivateKey) Signer {
+
+	return ed25519Signer{private, public}
+}
+
+func (s ed25519Signer) Alg() string              { return "EdDSA" }
+func (s ed25519Signer) Public() crypto.PublicKey { return s.public }
+func (s ed25519Signer) CanSign() bool            { return s.private != nil }
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	if s.private == nil {
+		return nil, ErrVerifyOnly
+	}
+	return ed25519.Sign(s.private, data), nil
+}
+
+func (s ed25519Signer) Verify(data, signature []byte) error {
+	if !ed25519.Verify(s.public, data, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
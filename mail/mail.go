@@ -0,0 +1,42 @@
+// Package mail sends the e-mails of the application through a Mailer, SMTP
+// delivers them and Outbox keeps them as files for local development.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a plain text e-mail
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages, the sender is set by the implementation
+type Mailer interface {
+	Send(context.Context, Message) error
+}
+
+// header drops line breaks so values cannot add headers of their own
+func header(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// bytes renders the message from sender in the RFC 5322 format
+func (m Message) bytes(from string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", header(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header(m.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.Replace(m.Body, "\n", "\r\n", -1))
+	return b.Bytes()
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Outbox writes messages as .eml files of a directory instead of sending
+// them, for local development and tests
+type Outbox struct {
+	dir  string
+	from string
+}
+
+// NewOutbox returns a mailer writing to dir, created when missing
+func NewOutbox(dir, from string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "cannot create outbox %s", dir)
+	}
+	return &Outbox{dir, from}, nil
+}
+
+// Send names the file after the current time so messages sort in the order
+// they were sent
+func (o *Outbox) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	if err := ioutil.WriteFile(filepath.Join(o.dir, name), m.bytes(o.from),
+		0600); err != nil {
+		return errors.Wrapf(err, "cannot write mail to %s", m.To)
+	}
+	return nil
+}
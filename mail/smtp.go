@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+
+	"github.com/pkg/errors"
+)
+
+// SMTP delivers messages to a mail server, upgrading the connection with
+// STARTTLS when the server offers it
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a mailer sending from the address from through the server
+// at addr, host:port. An empty user skips authentication.
+func NewSMTP(addr, from, user, password string) *SMTP {
+	var auth smtp.Auth
+	if user != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+	return &SMTP{addr, from, auth}
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{header(m.To)},
+		m.bytes(s.from)); err != nil {
+		return errors.Wrapf(err, "cannot send mail to %s", m.To)
+	}
+	return nil
+}
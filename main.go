@@ -1,25 +1,37 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"golang.org/x/time/rate"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
+	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/mail"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
+	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage/mongodb"
 )
 
 type app struct {
 	storage.Storage
-	jwtKeyFile string
-	staticDir  string
-	routes     []route
-	router     *mux.Router
+	keys      *jwt.KeySet
+	claims    jwt.Validator
+	staticDir string
+	routes    []route
+	router    *mux.Router
+	mailer    mail.Mailer
+	siteURL   string
 }
 
 var webapp app
@@ -28,14 +40,104 @@ func main() {
 
 	// cert := flag.String("cert", "server.crt", "public certificate")
 	// key := flag.String("key", "server.key", "private certificate")
-	jwtKey := flag.String("jwt", "jwt.key", "file with jwt key")
+	jwtKey := flag.String("jwt", "jwt.hmac",
+		"jwt key file or directory of keys named by key id")
+	jwtAlgs := flag.String("jwt-algs", strings.Join(jwt.Algorithms, ","),
+		"jwt algorithms accepted")
+	issuer := flag.String("jwt-issuer", "heapoverflow", "jwt iss claim")
+	audience := flag.String("jwt-audience", "heapoverflow", "jwt aud claim")
 	staticDir := flag.String("static", "frontend/dist", "static directory")
-	// openssl rand -out jwt.key -hex 256
+	timeouts := flag.Duration("timeout", 5*time.Second,
+		"storage deadline of each request")
+	admin := flag.String("admin", "", "e-mail of a registered user to make admin")
+	siteURL := flag.String("site-url", "http://localhost:8000",
+		"address of the site in mailed links")
+	smtpAddr := flag.String("smtp", "",
+		"host:port of the mail server, empty writes mails to -outbox")
+	smtpFrom := flag.String("smtp-from", "heapoverflow@localhost",
+		"sender of mails")
+	smtpUser := flag.String("smtp-user", "",
+		"mail server user, the password is read from $SMTP_PASSWORD")
+	outbox := flag.String("outbox", "outbox", "directory of unsent mails")
+	argonTime := flag.Uint("argon2-time", 0,
+		"argon2 passes of new password hashes, 0 keeps the default")
+	argonMemory := flag.Uint("argon2-memory", 0,
+		"argon2 KiB of new password hashes, 0 keeps the default")
+	argonThreads := flag.Uint("argon2-threads", 0,
+		"argon2 threads of new password hashes, 0 keeps the default")
+	argonLegacy := flag.Bool("argon2-legacy", true,
+		"verify password hashes of the former argon2 format")
+	pepper := flag.String("pepper", "",
+		"password pepper file or directory of peppers named by key id")
+	policyFile := flag.String("policy", "",
+		"JSON file of validation limits overriding the defaults")
+	breaches := flag.String("breaches", "",
+		"Have I Been Pwned passwords file or directory of range files")
+	highlight := flag.String("highlight",
+		strings.Join(markdown.DefaultLanguages, ","),
+		"languages of the highlighted code blocks, empty for none")
+	highlightMax := flag.Int("highlight-max", markdown.DefaultMaxHighlight,
+		"bytes of the largest code block highlighted")
+	// openssl rand -out jwt.hmac -hex 256
 
 	flag.Parse()
 
 	limits := &limit{rate.NewLimiter(10, 10)}
 
+	// stored hashes made with other parameters are upgraded on login
+	argonPolicy := []argon2.ARGON2Option{}
+	if *argonTime != 0 {
+		argonPolicy = append(argonPolicy, argon2.WithTime(uint32(*argonTime)))
+	}
+	if *argonMemory != 0 {
+		argonPolicy = append(argonPolicy,
+			argon2.WithMemory(uint32(*argonMemory)))
+	}
+	if *argonThreads != 0 {
+		argonPolicy = append(argonPolicy,
+			argon2.WithThreads(uint8(*argonThreads)))
+	}
+	argon2.SetPolicy(argonPolicy...)
+	argon2.SetLegacy(*argonLegacy)
+	if *pepper != "" {
+		peppers, err := argon2.LoadPeppers(*pepper)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		if err := argon2.SetPeppers(peppers...); err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+	}
+
+	policy := model.DefaultPolicy()
+	if *policyFile != "" {
+		loaded, err := model.LoadPolicy(*policyFile)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		policy = loaded
+	}
+	if *breaches != "" {
+		found, err := password.OpenBreaches(*breaches)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		policy.Password.Breaches = found
+	}
+	model.SetPolicy(policy)
+
+	langs := strings.FieldsFunc(*highlight, func(r rune) bool {
+		return r == ','
+	})
+	if err := markdown.SetHighlight(langs, *highlightMax); err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+
+	keys, err := jwt.LoadKeySet(*jwtKey, strings.Split(*jwtAlgs, ",")...)
+	if err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+
 	// app := app{
 	// 	storage.Storage{
 	// 		UserStorage:     db,
@@ -50,22 +152,41 @@ func main() {
 
 	// db, err := sql.New("database.db")
 	db, err := mongodb.New("localhost", "go-qa-forum", "users", "questions",
-		"comments")
+		"comments", "votes", "answers", "tags", "revisions", "sessions",
+		"apitokens", "twofactors", "loginattempts", "loginthrottles")
 	if err != nil {
 		log.Fatalf("%+v\n", err)
 	}
 	defer db.Close()
-	// db.DropTableIfExists("users", "questions", "comments")
-	// if err := db.AutoMigrate(&model.User{}, &model.Question{},
-	// &model.Comment{}).Error; err != nil {
+	// if err := db.Migrate(); err != nil {
 	// 	log.Fatalf("Error migrating db %s\n", err)
 	// }
 
-	webapp = app{db, *jwtKey, *staticDir, routes, mux.NewRouter()}
+	claims := jwt.Validator{
+		Issuer:   *issuer,
+		Audience: *audience,
+		Leeway:   jwt.DefaultLeeway,
+	}
+	var mailer mail.Mailer
+	if *smtpAddr != "" {
+		mailer = mail.NewSMTP(*smtpAddr, *smtpFrom, *smtpUser,
+			os.Getenv("SMTP_PASSWORD"))
+	} else if mailer, err = mail.NewOutbox(*outbox, *smtpFrom); err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+
+	webapp = app{db, keys, claims, *staticDir, routes, mux.NewRouter(),
+		mailer, strings.TrimSuffix(*siteURL, "/")}
+	if *admin != "" {
+		if err := webapp.bootstrapAdmin(context.Background(), *admin); err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+	}
 	webapp.registerRoutes(middleJSONLogger)
 	webapp.router.PathPrefix("/").Handler(http.FileServer(http.Dir(*staticDir)))
 	webapp.router.Use(
 		limits.toLimit,
+		timeout(*timeouts),
 		webapp.Validate,
 	)
 
@@ -0,0 +1,137 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/pkg/errors"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultMaxHighlight is the size in bytes of the largest code block highlighted
+const DefaultMaxHighlight = 2048
+
+// DefaultLanguages are the languages highlighted unless SetHighlight says
+// otherwise
+var DefaultLanguages = []string{"bash", "c", "c++", "c#", "css", "diff", "go",
+	"html", "java", "javascript", "json", "kotlin", "php", "python", "ruby",
+	"rust", "sql", "swift", "typescript", "yaml"}
+
+var (
+	languages    = lexerNames(DefaultLanguages)
+	maxHighlight = DefaultMaxHighlight
+)
+
+// formatter writes chroma classes, the stylesheet of the frontend colours them
+var formatter = chromahtml.New(chromahtml.WithClasses(true),
+	chromahtml.PreventSurroundingPre(true))
+
+// SetHighlight restricts highlighting to the fenced code blocks of languages,
+// names or aliases of chroma lexers, up to max bytes. Larger blocks and other
+// languages are only escaped. It is meant to be called at startup.
+func SetHighlight(langs []string, max int) error {
+	names := map[string]bool{}
+	for _, lang := range langs {
+		lexer := lexers.Get(lang)
+		if lexer == nil {
+			return errors.Errorf("unknown highlight language %q", lang)
+		}
+		names[lexer.Config().Name] = true
+	}
+	if max < 0 {
+		return errors.Errorf("invalid highlight size %d", max)
+	}
+	languages, maxHighlight = names, max
+	return nil
+}
+
+// WriteCSS writes the stylesheet colouring highlighted code with the chroma
+// style of name
+func WriteCSS(w io.Writer, name string) error {
+	style, ok := styles.Registry[strings.ToLower(name)]
+	if !ok {
+		return errors.Errorf("unknown highlight style %q", name)
+	}
+	return errors.Wrap(formatter.WriteCSS(w, style), "cannot write css")
+}
+
+func lexerNames(langs []string) map[string]bool {
+	names := map[string]bool{}
+	for _, lang := range langs {
+		if lexer := lexers.Get(lang); lexer != nil {
+			names[lexer.Config().Name] = true
+		}
+	}
+	return names
+}
+
+// highlighter renders fenced code blocks in place of the goldmark renderer
+type highlighter struct{}
+
+func (highlighter) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCode)
+}
+
+func renderFencedCode(w util.BufWriter, source []byte, node ast.Node,
+	entering bool) (ast.WalkStatus, error) {
+
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	for i := 0; i < block.Lines().Len(); i++ {
+		line := block.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+	lang := string(block.Language(source))
+
+	highlighted, ok := highlight(lang, code.String())
+	if ok {
+		w.WriteString(`<pre class="chroma">`)
+	} else {
+		w.WriteString("<pre>")
+	}
+	if lang != "" {
+		w.WriteString(`<code class="language-` + html.EscapeString(lang) +
+			`">`)
+	} else {
+		w.WriteString("<code>")
+	}
+	if ok {
+		w.WriteString(highlighted)
+	} else {
+		w.WriteString(html.EscapeString(code.String()))
+	}
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// highlight marks the tokens of code with chroma classes, it reports false
+// for languages out of the allow-list and code over the size cap
+func highlight(lang, code string) (string, bool) {
+	if lang == "" || len(code) > maxHighlight {
+		return "", false
+	}
+	lexer := lexers.Get(strings.ToLower(lang))
+	if lexer == nil || !languages[lexer.Config().Name] {
+		return "", false
+	}
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", false
+	}
+	var out strings.Builder
+	if err := formatter.Format(&out, styles.Fallback, tokens); err != nil {
+		return "", false
+	}
+	return out.String(), true
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// converter renders CommonMark with GitHub tables, strikethrough and bare
+// links, raw HTML of the source is dropped and fenced code highlighted
+var converter = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(
+			extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+		util.Prioritized(highlighter{}, 200))),
+)
+
+// sanitizer only lets through the elements Markdown produces, links to web
+// and mail addresses get rel="nofollow" so spam earns no ranking
+var sanitizer = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"blockquote", "em", "strong", "del", "code", "pre", "ul", "ol", "li",
+		"table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).
+		OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).
+		OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^chroma$`)).
+		OnElements("pre")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z]{1,8}$`)).
+		OnElements("span")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}()
+
+// Render converts the Markdown of src to HTML safe to embed in a page
+func Render(src string) string {
+	var out bytes.Buffer
+	if err := converter.Convert([]byte(src), &out); err != nil {
+		return "<p>" + html.EscapeString(src) + "</p>"
+	}
+	return sanitizer.Sanitize(out.String())
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"golang.org/x/time/rate"
 	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 type limit struct {
@@ -17,62 +23,130 @@ type limit struct {
 func (l *limit) toLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !l.Allow() {
-			http.Error(w, "Rate limiting", http.StatusTooManyRequests)
+			writeProblem(w, errRateLimited)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// timeout bounds every request context, storage calls made with it are
+// cancelled past d or as soon as the client goes away
+func timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func middleJSONLogger(fn appHandler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		toEncode := map[string]interface{}{}
-		w.Header().Set("Content-Type", "application/json")
-		payload := jwt.DecodePayload(r)
+		user := principalFrom(r).User
 
 		resp, err := fn(w, r)
 		if err != nil {
-			toEncode["error"] = err.Error()
-			toEncode["result"] = nil
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Printf("E: %s %s %s %+v %s\n", r.RemoteAddr, r.Method,
-				r.URL.Path, err, payload.Email)
-		} else {
-			toEncode["result"] = resp
-			log.Printf("C: %s %s %s %s\n", r.RemoteAddr, r.Method, r.URL.Path,
-				payload.Email)
+			p := writeProblem(w, err)
+			log.Printf("E: %s %s %s %d %+v %s\n", r.RemoteAddr, r.Method,
+				r.URL.Path, p.Status, err, user.Email)
+			return
+		}
+
+		if doc, ok := resp.(bare); ok {
+			log.Printf("C: %s %s %s %s\n", r.RemoteAddr, r.Method,
+				r.URL.Path, user.Email)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(doc.document)
+			return
 		}
+
+		if page, ok := resp.(paged); ok {
+			resp = page.result
+			toEncode["next"] = page.next
+		}
+		toEncode["result"] = resp
+		log.Printf("C: %s %s %s %s\n", r.RemoteAddr, r.Method, r.URL.Path,
+			user.Email)
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(toEncode)
 	})
 }
 
+// Validate authenticates every request but public routes and static files,
+// the principal of a valid token is stored in the request context. Public
+// routes get the principal when a valid token comes along.
 func (app *app) Validate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.isPublic(r) || staticWhiteList(app.staticDir, r.URL.Path) {
+		if staticWhiteList(app.staticDir, r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		header := r.Header.Get("Authorization")
-		if header == "" {
-			http.Error(w, "Missing JWT", http.StatusInternalServerError)
-			return
-		}
-
-		if !strings.HasPrefix(header, "Bearer ") {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		rawToken := header[len("Bearer "):]
-		token := jwt.NewFromFile(jwt.Payload{}, app.jwtKeyFile)
-		if err := token.Decode(rawToken); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		p, err := app.authenticate(r)
+		if app.isPublic(r) {
+			if err == nil {
+				r = r.WithContext(withPrincipal(r.Context(), p))
+			}
+			next.ServeHTTP(w, r)
 			return
 		}
-		if err := token.Check(); err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		if err != nil {
+			writeProblem(w, err)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
 	})
 }
+
+// authenticate verifies the bearer token of r and loads its user
+func (app *app) authenticate(r *http.Request) (principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return principal{}, errMissingToken
+	}
+	if !strings.HasPrefix(header, "Bearer ") {
+		return principal{}, errors.Wrap(errInvalidToken, "not a bearer token")
+	}
+
+	raw := header[len("Bearer "):]
+	if isAPIToken(raw) {
+		return app.authenticateAPIToken(r.Context(), raw)
+	}
+
+	token := jwt.New(jwt.Payload{}, app.keys)
+	if err := token.Decode(raw); err != nil {
+		return principal{}, errors.Wrap(errInvalidToken, err.Error())
+	}
+	if err := token.Check(app.claims); err != nil {
+		return principal{}, errors.Wrap(errInvalidToken, err.Error())
+	}
+	claims := token.Claims()
+
+	// access tokens die with their session on logout or refresh reuse
+	session, err := app.Storage.FindSession(r.Context(), claims.Sid)
+	if errors.Cause(err) == storage.ErrSessionNotFound ||
+		(err == nil && !session.Active()) {
+		return principal{}, errors.Wrap(errInvalidToken, "session revoked")
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	id, err := strconv.Atoi(claims.Sub)
+	if err != nil || id != session.UserID {
+		return principal{}, errors.Wrap(errInvalidToken, "invalid subject")
+	}
+	user, err := app.Storage.FindUser(r.Context(), id)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return principal{}, errors.Wrap(errInvalidToken, "unknown subject")
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	return principal{User: user, Session: session.ID, Claims: claims,
+		Scopes: model.Scopes}, nil
+}
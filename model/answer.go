@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Answer Content is rendered to ContentHTML like the Question one
+type Answer struct {
+	ID          int       `json:"id" bson:"_id"`
+	QuestionID  int       `json:"question" bson:"question_id"`
+	UserID      int       `json:"author" bson:"user_id"`
+	Content     string    `json:"content,omitempty" gorm:"size:2000"`
+	ContentHTML string    `json:"content_html,omitempty" bson:"content_html" gorm:"type:text"`
+	Votes       int       `json:"votes"`
+	Voted       int       `json:"voted" gorm:"-" bson:"-"`
+	Accepted    bool      `json:"accepted"`
+	When        time.Time `json:"when,omitempty"`
+	LastEdit    time.Time `json:"last_edit,omitempty" bson:"last_edit"`
+}
+
+func (a Answer) validContent() error {
+	if len(strings.TrimSpace(a.Content)) == 0 {
+		return invalid(ErrInvalidAnswer, "content", "required", nil,
+			"Invalid content: answer cannot be empty")
+	}
+	if max := policy.Post.ContentMaxSize; len(a.Content) > max {
+		return invalid(ErrInvalidAnswer, "content", "length", params{"max": max},
+			"Invalid content length must be below %d characters", max)
+	}
+	return nil
+}
+
+func (a Answer) Valid() error {
+	return validate(ErrInvalidAnswer, a.validContent)
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeVote  = "vote"
+	ScopeAdmin = "admin"
+
+	defaultTokenNameMaxSize = 64
+)
+
+// Scopes lists every scope an API token can be granted
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeVote, ScopeAdmin}
+
+var ErrInvalidAPIToken = errors.New("Invalid API token structure")
+
+// APIToken is a personal access token of a user for scripts. Scope is a space
+// separated list of Scopes, only the hash of the secret is kept and a zero
+// Expires never expires.
+type APIToken struct {
+	ID       int       `json:"id" bson:"_id"`
+	UserID   int       `json:"user" bson:"user_id" gorm:"index"`
+	Name     string    `json:"name" gorm:"size:64"`
+	Scope    string    `json:"scope" gorm:"size:64"`
+	Hash     string    `json:"-" bson:"hash" gorm:"unique_index;size:64"`
+	Expires  time.Time `json:"expires,omitempty" bson:"expires"`
+	LastUsed time.Time `json:"last_used,omitempty" bson:"last_used"`
+	When     time.Time `json:"when,omitempty"`
+}
+
+// Scopes returns the scopes granted to the token
+func (t APIToken) Scopes() []string {
+	return strings.Fields(t.Scope)
+}
+
+// Active tells whether the token is not expired
+func (t APIToken) Active() bool {
+	return t.Expires.IsZero() || time.Now().Before(t.Expires)
+}
+
+func (t APIToken) validName() error {
+	name := strings.TrimSpace(t.Name)
+	if max := policy.Token.NameMaxSize; name == "" || len(name) > max {
+		return invalid(ErrInvalidAPIToken, "name", "length",
+			params{"min": 1, "max": max},
+			"Invalid name length must be between 1 and %d characters", max)
+	}
+	return nil
+}
+
+func (t APIToken) validScope() error {
+	scopes := t.Scopes()
+	if len(scopes) == 0 {
+		return invalid(ErrInvalidAPIToken, "scope", "required",
+			params{"values": Scopes}, "At least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return invalid(ErrInvalidAPIToken, "scope", "enum",
+				params{"values": Scopes}, "Invalid scope %q", scope)
+		}
+	}
+	return nil
+}
+
+func (t APIToken) validExpires() error {
+	if !t.Expires.IsZero() && !t.Expires.After(time.Now()) {
+		return invalid(ErrInvalidAPIToken, "expires", "future", nil,
+			"Expiry must be in the future")
+	}
+	return nil
+}
+
+func (t APIToken) Valid() error {
+	return validate(ErrInvalidAPIToken, t.validName, t.validScope,
+		t.validExpires)
+}
+
+func validScope(scope string) bool {
+	for _, known := range Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
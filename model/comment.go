@@ -1,46 +1,31 @@
 package model
 
 import (
-	"strings"
 	"time"
-
-	"github.com/pkg/errors"
 )
 
+// Comment Content is rendered to ContentHTML like the Question one
 type Comment struct {
-	ID         int       `json:"id" bson:"_id"`
-	QuestionID int       `json:"question" bson:"question_id"`
-	UserID     int       `json:"author" bson:"user_id"`
-	Content    string    `json:"content,omitempty;size:2000"`
-	Votes      int       `json:"votes"`
-	When       time.Time `json:"when,omitempty"`
-	LastEdit   time.Time `json:"last_edit,omitempty" bson:"last_edit"`
+	ID          int       `json:"id" bson:"_id"`
+	QuestionID  int       `json:"question" bson:"question_id"`
+	AnswerID    int       `json:"answer,omitempty" bson:"answer_id"`
+	UserID      int       `json:"author" bson:"user_id"`
+	Content     string    `json:"content,omitempty;size:2000"`
+	ContentHTML string    `json:"content_html,omitempty" bson:"content_html" gorm:"type:text"`
+	Votes       int       `json:"votes"`
+	Voted       int       `json:"voted" gorm:"-" bson:"-"`
+	When        time.Time `json:"when,omitempty"`
+	LastEdit    time.Time `json:"last_edit,omitempty" bson:"last_edit"`
 }
 
 func (c Comment) validContent() error {
-	if len(c.Content) > defaultContentMaxSize {
-		return errors.Errorf("Invalid content length must be below %d characters",
-			defaultContentMaxSize)
+	if max := policy.Post.ContentMaxSize; len(c.Content) > max {
+		return invalid(ErrInvalidComment, "content", "length", params{"max": max},
+			"Invalid content length must be below %d characters", max)
 	}
 	return nil
 }
 
 func (c Comment) Valid() error {
-	validation := [](func() error){
-		c.validContent,
-	}
-
-	var errFound []string
-	for _, fn := range validation {
-		err := fn()
-		if err != nil {
-			errFound = append(errFound, err.Error())
-		}
-	}
-
-	if errFound == nil {
-		return nil
-	}
-
-	return errors.Errorf("%s", strings.Join(errFound, "\n"))
+	return validate(ErrInvalidComment, c.validContent)
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Reasons of failed logins recorded in LoginAttempt
+const (
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginInvalidCode        = "invalid_code"
+	LoginLocked             = "locked"
+)
+
+// LoginAttempt is the audit record of a failed login. Email is recorded lower
+// cased whether or not a user has it.
+type LoginAttempt struct {
+	ID     int       `json:"id" bson:"_id"`
+	Email  string    `json:"email" bson:"email" gorm:"index"`
+	IP     string    `json:"ip" bson:"ip" gorm:"size:45"`
+	Reason string    `json:"reason" bson:"reason" gorm:"size:32"`
+	When   time.Time `json:"when,omitempty"`
+}
+
+// LoginThrottle counts the consecutive failed logins of an email or an IP
+// address. ID is the throttled key, Last the time of the last failure.
+type LoginThrottle struct {
+	ID       string    `json:"id" bson:"_id" gorm:"primary_key"`
+	Failures int       `json:"failures" bson:"failures"`
+	Last     time.Time `json:"last" bson:"last"`
+}
@@ -19,6 +19,7 @@ var (
 	ErrInvalidUser     = errors.New("Invalid User structure")
 	ErrInvalidQuestion = errors.New("Invalid Question structure")
 	ErrInvalidComment  = errors.New("Invalid Comment structure")
+	ErrInvalidAnswer   = errors.New("Invalid Answer structure")
 )
 
 func oneUpperCase(s string) bool {
@@ -46,9 +47,15 @@ type passRules struct {
 	missing string
 }
 
-func seqOf(s string) bool {
-	for i := 0; i < (len(s) - 1); i++ {
-		if s[i] == s[i+1] {
+// seqOf reports more than max identical characters in a row
+func seqOf(s string, max int) bool {
+	run := 1
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[i-1] {
+			run = 1
+			continue
+		}
+		if run++; run > max {
 			return true
 		}
 	}
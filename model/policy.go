@@ -0,0 +1,172 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
+)
+
+var ErrInvalidPolicy = errors.New("Invalid Policy structure")
+
+// Policy holds the limits the validators enforce. A JSON file overrides the
+// defaults and clients read it to check their forms as the server does.
+type Policy struct {
+	User     UserPolicy     `json:"user"`
+	Password PasswordPolicy `json:"password"`
+	Post     PostPolicy     `json:"post"`
+	Tag      TagPolicy      `json:"tag"`
+	Token    TokenPolicy    `json:"token"`
+}
+
+type UserPolicy struct {
+	NickMaxSize   int `json:"nick_max_size"`
+	AvatarMaxSize int `json:"avatar_max_size"`
+	AvatarDim     int `json:"avatar_dim"`
+}
+
+// PasswordPolicy rejects short, guessable and breached passwords. MinClasses
+// is the number of classes among digits, special, lowercase and uppercase
+// characters a password mixes, MaxRepeat the longest run of one character, 0
+// for any, and MinScore the least password.Strength score. Passwords found in
+// Breaches more than MaxBreaches times are rejected when Breaches is set.
+type PasswordPolicy struct {
+	MinSize     int                `json:"min_size"`
+	MaxSize     int                `json:"max_size"`
+	MinClasses  int                `json:"min_classes"`
+	MaxRepeat   int                `json:"max_repeat"`
+	MinScore    int                `json:"min_score"`
+	MaxBreaches int                `json:"max_breaches"`
+	Breaches    *password.Breaches `json:"-"`
+}
+
+// PostPolicy bounds questions, answers, comments and revision summaries
+type PostPolicy struct {
+	TitleMinSize   int `json:"title_min_size"`
+	TitleMaxSize   int `json:"title_max_size"`
+	ContentMaxSize int `json:"content_max_size"`
+	SummaryMaxSize int `json:"summary_max_size"`
+}
+
+type TagPolicy struct {
+	NameMaxSize        int `json:"name_max_size"`
+	PerQuestion        int `json:"per_question"`
+	DescriptionMaxSize int `json:"description_max_size"`
+}
+
+type TokenPolicy struct {
+	NameMaxSize int `json:"name_max_size"`
+}
+
+var policy = DefaultPolicy()
+
+// DefaultPolicy returns the built-in limits, which are also the largest the
+// columns of the SQL storage hold
+func DefaultPolicy() Policy {
+	return Policy{
+		User: UserPolicy{
+			NickMaxSize:   defaultNickMaxSize,
+			AvatarMaxSize: defaultAvatarMaxSize,
+			AvatarDim:     defaultAvatarDim,
+		},
+		Password: PasswordPolicy{
+			MinSize:    defaultMinPasswordSize,
+			MaxSize:    defaultMaxPasswordSize,
+			MinClasses: defaultPasswordClasses,
+			MaxRepeat:  defaultPasswordRepeat,
+			MinScore:   defaultPasswordScore,
+		},
+		Post: PostPolicy{
+			TitleMinSize:   defaultTitleMinSize,
+			TitleMaxSize:   defaultTitleMaxSize,
+			ContentMaxSize: defaultContentMaxSize,
+			SummaryMaxSize: defaultSummaryMaxSize,
+		},
+		Tag: TagPolicy{
+			NameMaxSize:        defaultTagMaxSize,
+			PerQuestion:        defaultTagsPerQuestion,
+			DescriptionMaxSize: defaultTagDescriptionSize,
+		},
+		Token: TokenPolicy{
+			NameMaxSize: defaultTokenNameMaxSize,
+		},
+	}
+}
+
+// LoadPolicy reads the JSON policy file of path over the defaults, fields it
+// leaves out keep their default
+func LoadPolicy(path string) (Policy, error) {
+	p := DefaultPolicy()
+	f, err := os.Open(path)
+	if err != nil {
+		return Policy{}, errors.Wrap(err, "cannot open policy")
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		return Policy{}, errors.Wrapf(err, "cannot decode policy %s", path)
+	}
+	if err := p.Valid(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// SetPolicy replaces the limits of the validators, it is meant to be called
+// at startup before serving requests
+func SetPolicy(p Policy) {
+	policy = p
+}
+
+// CurrentPolicy returns the limits the validators enforce
+func CurrentPolicy() Policy {
+	return policy
+}
+
+// between checks a limit of field is within min and max
+func between(field string, value, min, max int) func() error {
+	return func() error {
+		if value < min || value > max {
+			return invalid(ErrInvalidPolicy, field, "range",
+				params{"min": min, "max": max},
+				"Invalid %s: must be between %d and %d", field, min, max)
+		}
+		return nil
+	}
+}
+
+func (p Policy) Valid() error {
+	return validate(ErrInvalidPolicy,
+		between("user.nick_max_size", p.User.NickMaxSize, 1,
+			defaultNickMaxSize),
+		between("user.avatar_max_size", p.User.AvatarMaxSize, 1, 1<<20),
+		between("user.avatar_dim", p.User.AvatarDim, 1, 1024),
+		between("password.min_size", p.Password.MinSize, 8,
+			p.Password.MaxSize),
+		between("password.max_size", p.Password.MaxSize, p.Password.MinSize,
+			1024),
+		between("password.min_classes", p.Password.MinClasses, 0, 4),
+		between("password.max_repeat", p.Password.MaxRepeat, 0,
+			p.Password.MaxSize),
+		between("password.min_score", p.Password.MinScore, 0, 4),
+		between("password.max_breaches", p.Password.MaxBreaches, 0,
+			1<<30),
+		between("post.title_min_size", p.Post.TitleMinSize, 1,
+			p.Post.TitleMaxSize),
+		between("post.title_max_size", p.Post.TitleMaxSize,
+			p.Post.TitleMinSize, defaultTitleMaxSize),
+		between("post.content_max_size", p.Post.ContentMaxSize, 1,
+			defaultContentMaxSize),
+		between("post.summary_max_size", p.Post.SummaryMaxSize, 0,
+			defaultSummaryMaxSize),
+		between("tag.name_max_size", p.Tag.NameMaxSize, 1, defaultTagMaxSize),
+		between("tag.per_question", p.Tag.PerQuestion, 0, 64),
+		between("tag.description_max_size", p.Tag.DescriptionMaxSize, 0,
+			defaultTagDescriptionSize),
+		between("token.name_max_size", p.Token.NameMaxSize, 1,
+			defaultTokenNameMaxSize),
+	)
+}
@@ -1,10 +1,7 @@
 package model
 
 import (
-	"strings"
 	"time"
-
-	"github.com/pkg/errors"
 )
 
 const (
@@ -12,48 +9,64 @@ const (
 	defaultTitleMinSize = 30
 )
 
+// Question Content is written in Markdown, ContentHTML is its sanitised HTML
+// rendered on save
 type Question struct {
-	ID       int       `json:"id" bson:"_id"`
-	Title    string    `json:"title,omitempty" gorm:"unique_index;size:140"`
-	Content  string    `json:"content,omitempty" gorm:"index;size:2000"`
-	Votes    int       `json:"votes"`
-	UserID   int       `json:"author" bson:"user_id"`
-	When     time.Time `json:"when,omitempty"`
-	LastEdit time.Time `json:"last_edit,omitempty"`
+	ID          int       `json:"id" bson:"_id"`
+	Title       string    `json:"title,omitempty" gorm:"unique_index;size:140"`
+	Content     string    `json:"content,omitempty" gorm:"index;size:2000"`
+	ContentHTML string    `json:"content_html,omitempty" bson:"content_html" gorm:"type:text"`
+	Votes       int       `json:"votes"`
+	Voted       int       `json:"voted" gorm:"-" bson:"-"`
+	UserID      int       `json:"author" bson:"user_id"`
+	Tags        []string  `json:"tags,omitempty" gorm:"-"`
+	When        time.Time `json:"when,omitempty"`
+	LastEdit    time.Time `json:"last_edit,omitempty"`
 }
 
 func (u Question) validTitle() error {
-	if len(u.Title) < defaultTitleMinSize || len(u.Title) > defaultTitleMaxSize {
-		return errors.Errorf("Invalid title length must be between %d and %d characters",
-			defaultTitleMinSize, defaultTitleMaxSize)
+	min, max := policy.Post.TitleMinSize, policy.Post.TitleMaxSize
+	if len(u.Title) < min || len(u.Title) > max {
+		return invalid(ErrInvalidQuestion, "title", "length",
+			params{"min": min, "max": max},
+			"Invalid title length must be between %d and %d characters",
+			min, max)
 	}
 	return nil
 }
 
 func (u Question) validContent() error {
-	if len(u.Content) > defaultContentMaxSize {
-		return errors.Errorf("Invalid content length must be below %d characters",
-			defaultContentMaxSize)
+	if max := policy.Post.ContentMaxSize; len(u.Content) > max {
+		return invalid(ErrInvalidQuestion, "content", "length",
+			params{"max": max},
+			"Invalid content length must be below %d characters", max)
 	}
 	return nil
 }
 
-func (q Question) Valid() error {
-	validation := [](func() error){
-		q.validContent,
-		q.validTitle,
+func (q Question) validTags() error {
+	if max := policy.Tag.PerQuestion; len(q.Tags) > max {
+		return invalid(ErrInvalidQuestion, "tags", "count", params{"max": max},
+			"Invalid tags: at most %d tags per question", max)
 	}
-
-	var errFound []string
-	for _, fn := range validation {
-		err := fn()
-		if err != nil {
-			errFound = append(errFound, err.Error())
+	seen := map[string]bool{}
+	for _, tag := range q.Tags {
+		if err := validTagName(ErrInvalidQuestion, "tags", tag); err != nil {
+			return err
 		}
+		if seen[tag] {
+			return invalid(ErrInvalidQuestion, "tags", "unique",
+				params{"tag": tag}, "Invalid tags: %q repeated", tag)
+		}
+		seen[tag] = true
 	}
-	if errFound == nil {
-		return nil
-	}
+	return nil
+}
 
-	return errors.Errorf("Invalid question model: %s", strings.Join(errFound, "\n"))
+func (q Question) Valid() error {
+	return validate(ErrInvalidQuestion,
+		q.validContent,
+		q.validTitle,
+		q.validTags,
+	)
 }
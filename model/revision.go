@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultSummaryMaxSize = 200
+
+var ErrInvalidRevision = errors.New("Invalid Revision structure")
+
+// Revision is a stored version of a question, answer or comment, Target uses
+// the vote target names and Number counts versions of the same target from 1
+type Revision struct {
+	ID       int       `json:"id" bson:"_id"`
+	Target   string    `json:"target" gorm:"unique_index:idx_revision;size:16"`
+	TargetID int       `json:"target_id" bson:"target_id" gorm:"unique_index:idx_revision"`
+	Number   int       `json:"number" gorm:"unique_index:idx_revision"`
+	UserID   int       `json:"author" bson:"user_id"`
+	Title    string    `json:"title,omitempty" gorm:"size:140"`
+	Content  string    `json:"content,omitempty" gorm:"size:2000"`
+	Summary  string    `json:"summary,omitempty" gorm:"size:200"`
+	When     time.Time `json:"when,omitempty"`
+}
+
+func (r Revision) Valid() error {
+	if r.Target != QuestionVote && r.Target != AnswerVote &&
+		r.Target != CommentVote {
+		return invalid(ErrInvalidRevision, "target", "enum",
+			params{"values": []string{QuestionVote, AnswerVote, CommentVote}},
+			"Invalid revision target %q", r.Target)
+	}
+	if max := policy.Post.SummaryMaxSize; len(r.Summary) > max {
+		return invalid(ErrInvalidRevision, "summary", "length",
+			params{"max": max},
+			"Invalid summary length must be below %d characters", max)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permission is an action reserved to some roles, routes requiring one are
+// refused to every other role
+type Permission string
+
+const (
+	PermEditPost    Permission = "edit_post"
+	PermDeletePost  Permission = "delete_post"
+	PermManageTags  Permission = "manage_tags"
+	PermManageUsers Permission = "manage_users"
+)
+
+var (
+	ErrInvalidRole = errors.New("Invalid role")
+	ErrForbidden   = errors.New("Not allowed")
+)
+
+// rolePermissions grants moderators every post on top of their own and admins
+// the users too, regular users hold no permission
+var rolePermissions = map[string][]Permission{
+	RoleModerator: {PermEditPost, PermDeletePost, PermManageTags},
+	RoleAdmin: {PermEditPost, PermDeletePost, PermManageTags,
+		PermManageUsers},
+}
+
+func ValidRole(role string) error {
+	switch role {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return nil
+	}
+	return ErrInvalidRole
+}
+
+// Can tells whether role grants perm, the empty permission is granted to all
+func Can(role string, perm Permission) bool {
+	if perm == "" {
+		return true
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
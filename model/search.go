@@ -0,0 +1,12 @@
+package model
+
+// SearchResult is a ranked match of a search, Kind is the matched model using
+// the vote target names and Snippet holds the matched text with terms marked
+type SearchResult struct {
+	Kind       string  `json:"kind"`
+	ID         int     `json:"id"`
+	QuestionID int     `json:"question"`
+	Title      string  `json:"title,omitempty"`
+	Snippet    string  `json:"snippet"`
+	Rank       float64 `json:"rank"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+)
+
+// maxRotated caps the rotated out token hashes a session remembers, older
+// tokens are merely invalid
+const maxRotated = 16
+
+// Session is a login of a user. It keeps the hash of the one refresh token
+// able to renew its access tokens, refresh tokens rotate on every use and
+// revoking the session also rejects the access tokens issued for it.
+// Rotated lists the hashes of the last rotated out tokens, space separated,
+// to tell a reused token from a forged one.
+type Session struct {
+	ID      string    `json:"id" bson:"_id" gorm:"primary_key;size:22"`
+	UserID  int       `json:"user" bson:"user_id" gorm:"index"`
+	Token   string    `json:"-" bson:"token" gorm:"size:64"`
+	Rotated string    `json:"-" bson:"rotated" gorm:"size:1040"`
+	Revoked bool      `json:"revoked" bson:"revoked"`
+	Expires time.Time `json:"expires" bson:"expires"`
+	When    time.Time `json:"when,omitempty"`
+}
+
+// Active tells whether the session can still be used
+func (s Session) Active() bool {
+	return !s.Revoked && time.Now().Before(s.Expires)
+}
+
+// Retire returns Rotated with the current token hash added
+func (s Session) Retire() string {
+	rotated := append([]string{s.Token}, strings.Fields(s.Rotated)...)
+	if len(rotated) > maxRotated {
+		rotated = rotated[:maxRotated]
+	}
+	return strings.Join(rotated, " ")
+}
+
+// Reused tells whether hash is of a token rotated out of the session
+func (s Session) Reused(hash string) bool {
+	reused := 0
+	for _, rotated := range strings.Fields(s.Rotated) {
+		reused |= subtle.ConstantTimeCompare([]byte(rotated), []byte(hash))
+	}
+	return reused == 1
+}
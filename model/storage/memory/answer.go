@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	question, err := db.FindQuestion(ctx, a.QuestionID)
+	if err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	if _, err := db.FindUser(ctx, a.UserID); err != nil {
+		return model.Answer{}, storage.ErrUserNotFound
+	}
+
+	a.When = time.Now()
+	a.LastEdit = time.Now()
+	a.QuestionID = question.ID
+	a.ContentHTML = markdown.Render(a.Content)
+	a.Votes = 0
+	a.Accepted = false
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+
+	db.lastAnswerID++
+	a.ID = db.lastAnswerID
+	db.answers = append(db.answers, a)
+	db.index(model.AnswerVote, a.ID, a.Content)
+
+	return a, nil
+}
+
+func (db *DB) UpdateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+	if _, err := db.FindQuestion(ctx, a.QuestionID); err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	for i, answer := range db.answers {
+		if a.ID == answer.ID {
+			db.answers[i].Content = a.Content
+			db.answers[i].ContentHTML = markdown.Render(a.Content)
+			db.answers[i].LastEdit = time.Now()
+			db.index(model.AnswerVote, a.ID, db.answers[i].Content)
+			return db.answers[i], nil
+		}
+	}
+	return model.Answer{}, storage.ErrAnswerNotFound
+}
+
+func (db *DB) DeleteAnswer(ctx context.Context, id int) error {
+	index := -1
+	for i, answer := range db.answers {
+		if id == answer.ID {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return storage.ErrAnswerNotFound
+	}
+
+	for _, comment := range db.commentsOf(db.answers[index].QuestionID, id) {
+		db.DeleteComment(ctx, comment)
+	}
+
+	db.answers = append(db.answers[:index], db.answers[index+1:]...)
+	db.unindex(model.AnswerVote, id)
+	db.dropVotes(model.AnswerVote, id)
+	db.dropRevisions(model.AnswerVote, id)
+
+	return nil
+}
+
+func (db *DB) FindAnswer(ctx context.Context, id int) (model.Answer, error) {
+	for _, answer := range db.answers {
+		if id == answer.ID {
+			return answer, nil
+		}
+	}
+	return model.Answer{}, storage.ErrAnswerNotFound
+}
+
+func (db *DB) FindAnswerByAuthor(ctx context.Context,
+	author int) ([]model.Answer, error) {
+
+	found := []model.Answer{}
+	for _, answer := range db.answers {
+		if answer.UserID == author {
+			found = append(found, answer)
+		}
+	}
+	if len(found) == 0 {
+		return nil, storage.ErrAnswerNotFound
+	}
+	return found, nil
+}
+
+func (db *DB) FindAnswerByQuestion(ctx context.Context,
+	question int) ([]model.Answer, error) {
+
+	if _, err := db.FindQuestion(ctx, question); err != nil {
+		return nil, storage.ErrQuestionNotFound
+	}
+
+	found := []model.Answer{}
+	for _, answer := range db.answers {
+		if answer.QuestionID == question {
+			found = append(found, answer)
+		}
+	}
+	return found, nil
+}
+
+func (db *DB) AcceptAnswer(ctx context.Context, question, id int) error {
+	if _, err := db.FindQuestion(ctx, question); err != nil {
+		return storage.ErrQuestionNotFound
+	}
+	if id != 0 {
+		answer, err := db.FindAnswer(ctx, id)
+		if err != nil || answer.QuestionID != question {
+			return storage.ErrAnswerNotFound
+		}
+	}
+
+	for i := range db.answers {
+		if db.answers[i].QuestionID == question {
+			db.answers[i].Accepted = db.answers[i].ID == id
+		}
+	}
+	return nil
+}
+
+// answersOf returns the ids of the answers of a question
+func (db *DB) answersOf(question int) []int {
+	ids := []int{}
+	for _, answer := range db.answers {
+		if answer.QuestionID == question {
+			ids = append(ids, answer.ID)
+		}
+	}
+	return ids
+}
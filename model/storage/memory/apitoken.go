@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAPIToken(ctx context.Context,
+	t model.APIToken) (model.APIToken, error) {
+
+	if err := t.Valid(); err != nil {
+		return model.APIToken{}, err
+	}
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.APIToken{}, storage.ErrUserNotFound
+	}
+
+	db.lastAPITokenID++
+	t.ID = db.lastAPITokenID
+	t.When = time.Now()
+	t.LastUsed = time.Time{}
+
+	db.apiTokens = append(db.apiTokens, t)
+
+	return t, nil
+}
+
+func (db *DB) TouchAPIToken(ctx context.Context, id int,
+	when time.Time) error {
+
+	for i, token := range db.apiTokens {
+		if token.ID == id {
+			db.apiTokens[i].LastUsed = when
+			return nil
+		}
+	}
+	return storage.ErrAPITokenNotFound
+}
+
+func (db *DB) DeleteAPIToken(ctx context.Context, id int) error {
+	for i, token := range db.apiTokens {
+		if token.ID == id {
+			db.apiTokens = append(db.apiTokens[:i], db.apiTokens[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrAPITokenNotFound
+}
+
+func (db *DB) FindAPIToken(ctx context.Context, id int) (model.APIToken,
+	error) {
+
+	for _, token := range db.apiTokens {
+		if token.ID == id {
+			return token, nil
+		}
+	}
+	return model.APIToken{}, storage.ErrAPITokenNotFound
+}
+
+func (db *DB) FindAPITokenByHash(ctx context.Context,
+	hash string) (model.APIToken, error) {
+
+	for _, token := range db.apiTokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return model.APIToken{}, storage.ErrAPITokenNotFound
+}
+
+func (db *DB) FindAPITokenByUser(ctx context.Context,
+	user int) ([]model.APIToken, error) {
+
+	found := []model.APIToken{}
+	for _, token := range db.apiTokens {
+		if token.UserID == user {
+			found = append(found, token)
+		}
+	}
+	return found, nil
+}
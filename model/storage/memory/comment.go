@@ -1,58 +1,88 @@
 package memory
 
 import (
-	"html"
+	"context"
+	"sort"
 	"time"
 
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) CreateComment(c model.Comment) (model.Comment, error) {
-	question, err := db.FindQuestion(c.QuestionID)
+func (db *DB) CreateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
+
+	question, err := db.FindQuestion(ctx, c.QuestionID)
 	if err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
 
-	if _, err := db.FindUser(c.UserID); err != nil {
+	if _, err := db.FindUser(ctx, c.UserID); err != nil {
 		return model.Comment{}, storage.ErrUserNotFound
 	}
 
-	c.ID = len(db.comments) + 1
+	if c.AnswerID != 0 {
+		answer, err := db.FindAnswer(ctx, c.AnswerID)
+		if err != nil || answer.QuestionID != question.ID {
+			return model.Comment{}, storage.ErrAnswerNotFound
+		}
+	}
+
 	c.When = time.Now()
 	c.LastEdit = time.Now()
 	c.QuestionID = question.ID
-	c.Content = html.EscapeString(c.Content)
+	c.ContentHTML = markdown.Render(c.Content)
 	c.Votes = 0
 
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
 
+	db.lastCommentID++
+	c.ID = db.lastCommentID
 	db.comments = append(db.comments, c)
+	db.index(model.CommentVote, c.ID, c.Content)
 
 	return c, nil
 }
 
-func (db *DB) UpdateComment(c model.Comment) (model.Comment, error) {
+func (db *DB) UpdateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
+
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
-	if _, err := db.FindQuestion(c.QuestionID); err != nil {
+	if _, err := db.FindQuestion(ctx, c.QuestionID); err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
 
 	for i, comment := range db.comments {
 		if c.ID == comment.ID {
-			db.comments[i].Content = html.EscapeString(c.Content)
+			db.comments[i].Content = c.Content
+			db.comments[i].ContentHTML = markdown.Render(c.Content)
 			db.comments[i].LastEdit = time.Now()
+			db.index(model.CommentVote, c.ID, db.comments[i].Content)
 			return db.comments[i], nil
 		}
 	}
 	return model.Comment{}, storage.ErrCommentNotFound
 }
 
-func (db *DB) FindComment(id int) (model.Comment, error) {
+func (db *DB) DeleteComment(ctx context.Context, id int) error {
+	for i, comment := range db.comments {
+		if id == comment.ID {
+			db.comments = append(db.comments[:i], db.comments[i+1:]...)
+			db.unindex(model.CommentVote, id)
+			db.dropVotes(model.CommentVote, id)
+			db.dropRevisions(model.CommentVote, id)
+			return nil
+		}
+	}
+	return storage.ErrCommentNotFound
+}
+
+func (db *DB) FindComment(ctx context.Context, id int) (model.Comment, error) {
 	for _, comment := range db.comments {
 		if id == comment.ID {
 			return comment, nil
@@ -61,7 +91,9 @@ func (db *DB) FindComment(id int) (model.Comment, error) {
 	return model.Comment{}, storage.ErrCommentNotFound
 }
 
-func (db *DB) FindCommentByAuthor(author int) ([]model.Comment, error) {
+func (db *DB) FindCommentByAuthor(ctx context.Context,
+	author int) ([]model.Comment, error) {
+
 	found := []model.Comment{}
 	for _, comment := range db.comments {
 		if comment.UserID == author {
@@ -74,39 +106,69 @@ func (db *DB) FindCommentByAuthor(author int) ([]model.Comment, error) {
 	return found, nil
 }
 
-func (db *DB) FindCommentByQuestion(question int) ([]model.Comment, error) {
-	if _, err := db.FindQuestion(question); err != nil {
-		return nil, storage.ErrQuestionNotFound
+func (db *DB) FindCommentByQuestion(ctx context.Context, question int,
+	query storage.Query) ([]model.Comment, string, error) {
+
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := db.FindQuestion(ctx, question); err != nil {
+		return nil, "", storage.ErrQuestionNotFound
 	}
 
 	found := []model.Comment{}
 	for _, comment := range db.comments {
-		if comment.QuestionID == question {
+		if comment.QuestionID == question && comment.AnswerID == 0 &&
+			(query.Author == 0 || comment.UserID == query.Author) {
 			found = append(found, comment)
 		}
 	}
-	if len(found) == 0 {
-		return nil, storage.ErrCommentNotFound
-	}
-	return found, nil
+
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch page.Sort {
+		case "votes":
+			return ordered(page, compareInt(a.Votes, b.Votes))
+		case "when":
+			return ordered(page, compareTime(a.When, b.When))
+		case "last_edit":
+			return ordered(page, compareTime(a.LastEdit, b.LastEdit))
+		}
+		return false
+	})
+
+	start, end, next := window(page, len(found))
+	return found[start:end], next, nil
 }
 
-func (db *DB) UpComment(id int) error {
-	comment, err := db.FindComment(id)
-	if err != nil {
-		return err
+func (db *DB) FindCommentByAnswer(ctx context.Context,
+	answer int) ([]model.Comment, error) {
+
+	if _, err := db.FindAnswer(ctx, answer); err != nil {
+		return nil, storage.ErrAnswerNotFound
 	}
 
-	comment.Votes++
-	return nil
+	found := []model.Comment{}
+	for _, comment := range db.comments {
+		if comment.AnswerID == answer {
+			found = append(found, comment)
+		}
+	}
+	if len(found) == 0 {
+		return nil, storage.ErrCommentNotFound
+	}
+	return found, nil
 }
 
-func (db *DB) DownComment(id int) error {
-	comment, err := db.FindComment(id)
-	if err != nil {
-		return err
+// commentsOf returns the ids of the comments of a question, or of one of its
+// answers when answer is not 0
+func (db *DB) commentsOf(question, answer int) []int {
+	ids := []int{}
+	for _, comment := range db.comments {
+		if comment.QuestionID == question && comment.AnswerID == answer {
+			ids = append(ids, comment.ID)
+		}
 	}
-
-	comment.Votes--
-	return nil
+	return ids
 }
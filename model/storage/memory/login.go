@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateLoginAttempt(ctx context.Context,
+	a model.LoginAttempt) (model.LoginAttempt, error) {
+
+	db.lastLoginAttemptID++
+	a.ID = db.lastLoginAttemptID
+	a.When = time.Now()
+
+	db.loginAttempts = append(db.loginAttempts, a)
+
+	return a, nil
+}
+
+func (db *DB) FailLogin(ctx context.Context, key string,
+	window time.Duration) (model.LoginThrottle, error) {
+
+	now := time.Now()
+	for i, throttle := range db.loginThrottles {
+		if throttle.ID == key {
+			if now.Sub(throttle.Last) > window {
+				throttle.Failures = 0
+			}
+			throttle.Failures++
+			throttle.Last = now
+			db.loginThrottles[i] = throttle
+			return throttle, nil
+		}
+	}
+
+	throttle := model.LoginThrottle{ID: key, Failures: 1, Last: now}
+	db.loginThrottles = append(db.loginThrottles, throttle)
+
+	return throttle, nil
+}
+
+func (db *DB) ClearLoginThrottle(ctx context.Context, key string) error {
+	for i, throttle := range db.loginThrottles {
+		if throttle.ID == key {
+			db.loginThrottles = append(db.loginThrottles[:i],
+				db.loginThrottles[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (db *DB) FindLoginAttempts(ctx context.Context, email string,
+	query storage.Query) ([]model.LoginAttempt, string, error) {
+
+	page, err := query.Page("when")
+	if err != nil {
+		return nil, "", err
+	}
+
+	found := []model.LoginAttempt{}
+	for _, attempt := range db.loginAttempts {
+		if attempt.Email == email {
+			found = append(found, attempt)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if page.Sort == "when" {
+			return ordered(page, compareTime(found[i].When, found[j].When))
+		}
+		return false
+	})
+
+	start, end, next := window(page, len(found))
+	return found[start:end], next, nil
+}
+
+func (db *DB) FindLoginThrottle(ctx context.Context,
+	key string) (model.LoginThrottle, error) {
+
+	for _, throttle := range db.loginThrottles {
+		if throttle.ID == key {
+			return throttle, nil
+		}
+	}
+	return model.LoginThrottle{ID: key}, nil
+}
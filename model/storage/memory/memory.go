@@ -1,15 +1,90 @@
 package memory
 
 import (
+	"sync"
+	"time"
+
 	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 type DB struct {
-	users     []model.User
-	questions []model.Question
-	comments  []model.Comment
+	users      []model.User
+	questions  []model.Question
+	answers    []model.Answer
+	comments   []model.Comment
+	votes      []model.Vote
+	tags       []model.Tag
+	revisions  []model.Revision
+	sessions   []model.Session
+	apiTokens  []model.APIToken
+	twoFactors []model.TwoFactor
+	lastVoteID int
+
+	loginAttempts      []model.LoginAttempt
+	loginThrottles     []model.LoginThrottle
+	lastLoginAttemptID int
+
+	// posts can be deleted so their ids cannot follow the slice length
+	lastQuestionID int
+	lastAnswerID   int
+	lastCommentID  int
+	lastAPITokenID int
+	lastRevisionID int
+
+	// inverted index of term frequencies per document, every request
+	// changing posts updates it so it has a lock of its own
+	searchMu sync.RWMutex
+	postings map[string]map[document]int
+	indexed  map[document][]string
 }
 
 func New() *DB {
-	return &DB{}
+	return &DB{
+		postings: map[string]map[document]int{},
+		indexed:  map[document][]string{},
+	}
+}
+
+// ordered tells whether a goes before b in the page given c, the result of
+// comparing a and b on the sort field the same way as strings.Compare
+func ordered(page storage.Page, c int) bool {
+	if page.Desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+// window returns the bounds of the page over n sorted items and the cursor of
+// the next page
+func window(page storage.Page, n int) (int, int, string) {
+	start := page.Offset
+	if start > n {
+		start = n
+	}
+	end := start + page.Limit
+	if end > n {
+		end = n
+	}
+	return start, end, page.Next(n - start)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
 }
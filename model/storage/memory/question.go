@@ -1,58 +1,136 @@
 package memory
 
 import (
-	"html"
+	"context"
+	"sort"
 	"time"
 
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) FindAllQuestion() ([]model.Question, error) {
-	return db.questions, nil
+func (db *DB) FindAllQuestion(ctx context.Context,
+	query storage.Query) ([]model.Question, string, error) {
+
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
+	}
+	tags := db.resolveTags(ctx, query.Tags)
+
+	found := []model.Question{}
+	for _, question := range db.questions {
+		if (query.Author == 0 || question.UserID == query.Author) &&
+			hasTags(question, tags) {
+			found = append(found, question)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch page.Sort {
+		case "votes":
+			return ordered(page, compareInt(a.Votes, b.Votes))
+		case "when":
+			return ordered(page, compareTime(a.When, b.When))
+		case "last_edit":
+			return ordered(page, compareTime(a.LastEdit, b.LastEdit))
+		}
+		return false
+	})
+
+	start, end, next := window(page, len(found))
+	return found[start:end], next, nil
 }
 
-func (db *DB) CreateQuestion(q model.Question) (model.Question, error) {
-	if _, err := db.FindQuestionByTitle(q.Title); err == nil {
+func (db *DB) CreateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
+
+	if _, err := db.FindQuestionByTitle(ctx, q.Title); err == nil {
 		return model.Question{}, storage.ErrQuestionAlreadyExist
 	}
 
-	if _, err := db.FindUser(q.UserID); err != nil {
+	if _, err := db.FindUser(ctx, q.UserID); err != nil {
 		return model.Question{}, storage.ErrUserNotFound
 	}
 
-	q.ID = len(db.questions) + 1
 	q.When = time.Now()
 	q.LastEdit = time.Now()
 	q.Votes = 0
-	q.Title = html.EscapeString(q.Title)
-	q.Content = html.EscapeString(q.Content)
+	q.ContentHTML = markdown.Render(q.Content)
+	q.Tags = db.resolveTags(ctx, q.Tags)
 
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
 
+	db.lastQuestionID++
+	q.ID = db.lastQuestionID
+	db.registerTags(ctx, q.Tags)
 	db.questions = append(db.questions, q)
+	db.index(model.QuestionVote, q.ID, q.Title, q.Content)
 
 	return q, nil
 }
 
-func (db *DB) UpdateQuestion(q model.Question) (model.Question, error) {
+func (db *DB) UpdateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
+
+	if q.Tags != nil {
+		q.Tags = db.resolveTags(ctx, q.Tags)
+	}
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
 	for i, question := range db.questions {
 		if q.ID == question.ID {
-			db.questions[i].Title = html.EscapeString(q.Title)
-			db.questions[i].Content = html.EscapeString(q.Content)
+			if q.Tags != nil {
+				db.registerTags(ctx, q.Tags)
+				db.questions[i].Tags = q.Tags
+			}
+			db.questions[i].Title = q.Title
+			db.questions[i].Content = q.Content
+			db.questions[i].ContentHTML = markdown.Render(q.Content)
 			db.questions[i].LastEdit = time.Now()
+			db.index(model.QuestionVote, q.ID, db.questions[i].Title,
+				db.questions[i].Content)
 			return db.questions[i], nil
 		}
 	}
 	return model.Question{}, storage.ErrQuestionNotFound
 }
 
-func (db *DB) FindQuestion(id int) (model.Question, error) {
+func (db *DB) DeleteQuestion(ctx context.Context, id int) error {
+	index := -1
+	for i, question := range db.questions {
+		if id == question.ID {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return storage.ErrQuestionNotFound
+	}
+
+	for _, answer := range db.answersOf(id) {
+		db.DeleteAnswer(ctx, answer)
+	}
+	for _, comment := range db.commentsOf(id, 0) {
+		db.DeleteComment(ctx, comment)
+	}
+
+	db.questions = append(db.questions[:index], db.questions[index+1:]...)
+	db.unindex(model.QuestionVote, id)
+	db.dropVotes(model.QuestionVote, id)
+	db.dropRevisions(model.QuestionVote, id)
+
+	return nil
+}
+
+func (db *DB) FindQuestion(ctx context.Context, id int) (model.Question,
+	error) {
+
 	for _, question := range db.questions {
 		if id == question.ID {
 			return question, nil
@@ -61,7 +139,9 @@ func (db *DB) FindQuestion(id int) (model.Question, error) {
 	return model.Question{}, storage.ErrQuestionNotFound
 }
 
-func (db *DB) FindQuestionByTitle(title string) (model.Question, error) {
+func (db *DB) FindQuestionByTitle(ctx context.Context,
+	title string) (model.Question, error) {
+
 	for _, question := range db.questions {
 		if question.Title == title {
 			return question, nil
@@ -70,7 +150,9 @@ func (db *DB) FindQuestionByTitle(title string) (model.Question, error) {
 	return model.Question{}, storage.ErrQuestionNotFound
 }
 
-func (db *DB) FindQuestionByAuthor(author int) ([]model.Question, error) {
+func (db *DB) FindQuestionByAuthor(ctx context.Context,
+	author int) ([]model.Question, error) {
+
 	found := []model.Question{}
 	for _, question := range db.questions {
 		if question.UserID == author {
@@ -83,22 +165,18 @@ func (db *DB) FindQuestionByAuthor(author int) ([]model.Question, error) {
 	return found, nil
 }
 
-func (db *DB) UpQuestion(id int) error {
-	question, err := db.FindQuestion(id)
-	if err != nil {
-		return err
-	}
-
-	question.Votes++
-	return nil
-}
-
-func (db *DB) DownQuestion(id int) error {
-	question, err := db.FindQuestion(id)
-	if err != nil {
-		return err
+func hasTags(question model.Question, tags []string) bool {
+	for _, want := range tags {
+		tagged := false
+		for _, tag := range question.Tags {
+			if tag == want {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			return false
+		}
 	}
-
-	question.Votes--
-	return nil
+	return true
 }
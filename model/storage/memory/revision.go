@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateRevision(ctx context.Context,
+	r model.Revision) (model.Revision, error) {
+
+	if err := r.Valid(); err != nil {
+		return model.Revision{}, err
+	}
+
+	revisions, err := db.FindRevisionByTarget(ctx, r.Target, r.TargetID)
+	if err != nil {
+		return model.Revision{}, err
+	}
+
+	db.lastRevisionID++
+	r.ID = db.lastRevisionID
+	r.Number = len(revisions) + 1
+	if r.When.IsZero() {
+		r.When = time.Now()
+	}
+
+	db.revisions = append(db.revisions, r)
+
+	return r, nil
+}
+
+func (db *DB) FindRevision(ctx context.Context, target string, id,
+	number int) (model.Revision, error) {
+
+	for _, revision := range db.revisions {
+		if revision.Target == target && revision.TargetID == id &&
+			revision.Number == number {
+			return revision, nil
+		}
+	}
+	return model.Revision{}, storage.ErrRevisionNotFound
+}
+
+func (db *DB) FindRevisionByTarget(ctx context.Context, target string,
+	id int) ([]model.Revision, error) {
+
+	found := []model.Revision{}
+	for _, revision := range db.revisions {
+		if revision.Target == target && revision.TargetID == id {
+			found = append(found, revision)
+		}
+	}
+	return found, nil
+}
+
+// dropRevisions removes the history of a deleted target
+func (db *DB) dropRevisions(target string, id int) {
+	kept := db.revisions[:0]
+	for _, revision := range db.revisions {
+		if revision.Target != target || revision.TargetID != id {
+			kept = append(kept, revision)
+		}
+	}
+	db.revisions = kept
+}
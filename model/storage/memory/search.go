@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// document identifies an indexed question, answer or comment
+type document struct {
+	kind string
+	id   int
+}
+
+func (db *DB) Search(ctx context.Context, search string,
+	query storage.Query) ([]model.SearchResult, string, error) {
+
+	page, err := query.Page()
+	if err != nil {
+		return nil, "", err
+	}
+	terms := storage.Terms(search)
+	if len(terms) == 0 {
+		return nil, "", storage.ErrEmptySearch
+	}
+
+	// documents are ranked under the index lock and loaded after it
+	ranks := map[document]float64{}
+	db.searchMu.RLock()
+	for doc := range db.postings[terms[0]] {
+		rank := 0.0
+		for _, term := range terms {
+			postings := db.postings[term]
+			frequency, found := postings[doc]
+			if !found {
+				rank = 0
+				break
+			}
+			idf := math.Log(1 + float64(len(db.indexed))/float64(len(postings)))
+			rank += float64(frequency) * idf
+		}
+		if rank != 0 {
+			ranks[doc] = rank
+		}
+	}
+	db.searchMu.RUnlock()
+
+	results := []model.SearchResult{}
+	for doc, rank := range ranks {
+		result, err := db.searchResult(ctx, doc, terms)
+		if err != nil {
+			continue
+		}
+		result.Rank = rank
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ID < b.ID
+	})
+
+	start, end, next := window(page, len(results))
+	return results[start:end], next, nil
+}
+
+func (db *DB) searchResult(ctx context.Context, doc document,
+	terms []string) (model.SearchResult, error) {
+
+	result := model.SearchResult{Kind: doc.kind, ID: doc.id}
+	var title, content string
+	switch doc.kind {
+	case model.QuestionVote:
+		question, err := db.FindQuestion(ctx, doc.id)
+		if err != nil {
+			return model.SearchResult{}, err
+		}
+		result.QuestionID = question.ID
+		title, content = question.Title, question.Content
+	case model.AnswerVote:
+		answer, err := db.FindAnswer(ctx, doc.id)
+		if err != nil {
+			return model.SearchResult{}, err
+		}
+		result.QuestionID = answer.QuestionID
+		content = answer.Content
+	case model.CommentVote:
+		comment, err := db.FindComment(ctx, doc.id)
+		if err != nil {
+			return model.SearchResult{}, err
+		}
+		result.QuestionID = comment.QuestionID
+		content = comment.Content
+	}
+
+	result.Title = title
+	snippet, found := storage.Snippet(content, terms)
+	if !found && title != "" {
+		snippet, _ = storage.Snippet(title, terms)
+	}
+	result.Snippet = snippet
+	return result, nil
+}
+
+// index replaces the indexed terms of a document
+func (db *DB) index(kind string, id int, text ...string) {
+	db.searchMu.Lock()
+	defer db.searchMu.Unlock()
+	db.reindex(document{kind, id}, text...)
+}
+
+// unindex removes a deleted document from the index
+func (db *DB) unindex(kind string, id int) {
+	db.searchMu.Lock()
+	defer db.searchMu.Unlock()
+	doc := document{kind, id}
+	db.reindex(doc)
+	delete(db.indexed, doc)
+}
+
+// reindex replaces the terms of doc, the caller holds the index lock
+func (db *DB) reindex(doc document, text ...string) {
+	for _, term := range db.indexed[doc] {
+		delete(db.postings[term], doc)
+		if len(db.postings[term]) == 0 {
+			delete(db.postings, term)
+		}
+	}
+
+	terms := storage.Terms(strings.Join(text, " "))
+	for _, term := range terms {
+		if db.postings[term] == nil {
+			db.postings[term] = map[document]int{}
+		}
+		db.postings[term][doc]++
+	}
+	db.indexed[doc] = terms
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateSession(ctx context.Context,
+	s model.Session) (model.Session, error) {
+
+	s.When = time.Now()
+	db.sessions = append(db.sessions, s)
+
+	return s, nil
+}
+
+func (db *DB) RotateSession(ctx context.Context, id, old,
+	token string) (model.Session, error) {
+
+	for i, session := range db.sessions {
+		if session.ID == id && session.Token == old && session.Active() {
+			db.sessions[i].Rotated = session.Retire()
+			db.sessions[i].Token = token
+			return db.sessions[i], nil
+		}
+	}
+	return model.Session{}, storage.ErrSessionNotFound
+}
+
+func (db *DB) RevokeSession(ctx context.Context, id string) error {
+	for i, session := range db.sessions {
+		if session.ID == id {
+			db.sessions[i].Revoked = true
+			return nil
+		}
+	}
+	return storage.ErrSessionNotFound
+}
+
+func (db *DB) FindSession(ctx context.Context, id string) (model.Session,
+	error) {
+
+	for _, session := range db.sessions {
+		if session.ID == id {
+			return session, nil
+		}
+	}
+	return model.Session{}, storage.ErrSessionNotFound
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) FindAllTag(ctx context.Context) ([]model.Tag, error) {
+	tags := make([]model.Tag, len(db.tags))
+	copy(tags, db.tags)
+	for i := range tags {
+		tags[i].Count = db.countTag(tags[i].Name)
+	}
+	return tags, nil
+}
+
+func (db *DB) UpdateTag(ctx context.Context, t model.Tag) (model.Tag, error) {
+	t.Name = model.NormalizeTag(t.Name)
+	for i, tag := range db.tags {
+		if tag.Name == t.Name {
+			db.tags[i].Description = t.Description
+			if err := db.tags[i].Valid(); err != nil {
+				db.tags[i].Description = tag.Description
+				return model.Tag{}, err
+			}
+			updated := db.tags[i]
+			updated.Count = db.countTag(updated.Name)
+			return updated, nil
+		}
+	}
+	return model.Tag{}, storage.ErrTagNotFound
+}
+
+func (db *DB) FindTag(ctx context.Context, name string) (model.Tag, error) {
+	name = model.NormalizeTag(name)
+	for _, tag := range db.tags {
+		if tag.Name == name {
+			tag.Count = db.countTag(tag.Name)
+			return tag, nil
+		}
+	}
+	return model.Tag{}, storage.ErrTagNotFound
+}
+
+func (db *DB) MergeTag(ctx context.Context, from, to string) error {
+	from, to = model.NormalizeTag(from), model.NormalizeTag(to)
+	if _, err := db.FindTag(ctx, from); err != nil {
+		return err
+	}
+	to = db.resolveTags(ctx, []string{to})[0]
+	if from == to {
+		return model.ErrInvalidTag
+	}
+	if err := model.ValidTagName(to); err != nil {
+		return err
+	}
+	db.registerTags(ctx, []string{to})
+
+	for i, question := range db.questions {
+		tags := make([]string, len(question.Tags))
+		for j, tag := range question.Tags {
+			if tag == from {
+				tag = to
+			}
+			tags[j] = tag
+		}
+		db.questions[i].Tags = model.NormalizeTags(tags)
+	}
+
+	for i, tag := range db.tags {
+		if tag.Name == from || tag.SynonymOf == from {
+			db.tags[i].SynonymOf = to
+		}
+	}
+	return nil
+}
+
+// resolveTags normalizes names replacing synonyms by their canonical tag
+func (db *DB) resolveTags(ctx context.Context, names []string) []string {
+	tags := model.NormalizeTags(names)
+	for i, name := range tags {
+		if tag, err := db.FindTag(ctx, name); err == nil && tag.SynonymOf != "" {
+			tags[i] = tag.SynonymOf
+		}
+	}
+	return model.NormalizeTags(tags)
+}
+
+// registerTags adds unknown tags to the catalogue
+func (db *DB) registerTags(ctx context.Context, names []string) {
+	for _, name := range names {
+		if _, err := db.FindTag(ctx, name); err != nil {
+			db.tags = append(db.tags, model.Tag{Name: name})
+		}
+	}
+}
+
+func (db *DB) countTag(name string) int {
+	count := 0
+	for _, question := range db.questions {
+		for _, tag := range question.Tags {
+			if tag == name {
+				count++
+			}
+		}
+	}
+	return count
+}
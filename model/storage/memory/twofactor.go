@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) SaveTwoFactor(ctx context.Context,
+	t model.TwoFactor) (model.TwoFactor, error) {
+
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.TwoFactor{}, storage.ErrUserNotFound
+	}
+
+	t.When = time.Now()
+	for i, stored := range db.twoFactors {
+		if stored.UserID == t.UserID {
+			db.twoFactors[i] = t
+			return t, nil
+		}
+	}
+	db.twoFactors = append(db.twoFactors, t)
+
+	return t, nil
+}
+
+func (db *DB) UpdateTwoFactor(ctx context.Context, old,
+	t model.TwoFactor) error {
+
+	for i, stored := range db.twoFactors {
+		if stored.UserID == old.UserID && stored.LastStep == old.LastStep &&
+			stored.Recovery == old.Recovery {
+			t.UserID = stored.UserID
+			db.twoFactors[i] = t
+			return nil
+		}
+	}
+	return storage.ErrTwoFactorNotFound
+}
+
+func (db *DB) DeleteTwoFactor(ctx context.Context, user int) error {
+	for i, stored := range db.twoFactors {
+		if stored.UserID == user {
+			db.twoFactors = append(db.twoFactors[:i], db.twoFactors[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrTwoFactorNotFound
+}
+
+func (db *DB) FindTwoFactor(ctx context.Context, user int) (model.TwoFactor,
+	error) {
+
+	for _, stored := range db.twoFactors {
+		if stored.UserID == user {
+			return stored, nil
+		}
+	}
+	return model.TwoFactor{}, storage.ErrTwoFactorNotFound
+}
@@ -1,38 +1,70 @@
 package memory
 
 import (
+	"context"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) Login(login string, pass string) error {
-	user, err := db.FindUserByEmail(login)
-	if err != nil {
-		argon2.CompareHashAndPassword([]byte("not found"), []byte(pass))
-		return errors.Errorf("user or pass invalid")
+func (db *DB) Login(ctx context.Context, login string, pass string) error {
+	// FindUserByEmail omits the password hash the comparison needs
+	var user model.User
+	found := false
+	for _, stored := range db.users {
+		if login == stored.Email {
+			user, found = stored, true
+			break
+		}
+	}
+	if !found {
+		password.CompareDummy([]byte(pass))
+		return storage.ErrInvalidCredentials
 	}
-	if err := argon2.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
-		return errors.Errorf("user or pass invalid")
+	if err := password.Compare([]byte(user.Password), []byte(pass)); err != nil {
+		return storage.ErrInvalidCredentials
+	}
+	if password.NeedsRehash([]byte(user.Password)) {
+		db.rehash(user, pass)
 	}
 	return nil
 }
 
-func (db *DB) CreateUser(u model.User) (model.User, error) {
-	if _, err := db.FindUserByNick(u.Nick); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+// rehash upgrades the password hash of user to argon2id with the current policy
+// while the password is at hand, the old hash keeps working when it fails
+func (db *DB) rehash(user model.User, pass string) {
+	newPass, err := model.GenPass(pass)
+	if err != nil {
+		return
+	}
+	for i := range db.users {
+		if db.users[i].ID == user.ID && db.users[i].Password == user.Password {
+			db.users[i].Password = newPass
+		}
+	}
+}
+
+func (db *DB) CreateUser(ctx context.Context, u model.User) (model.User,
+	error) {
+
+	if _, err := db.FindUserByNick(ctx, u.Nick); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
-	if _, err := db.FindUserByEmail(u.Email); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+	if _, err := db.FindUserByEmail(ctx, u.Email); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
 
 	u.ID = len(db.users) + 1
 	u.Since = time.Now()
+	u.Role = model.RoleUser
+	u.Verified = false
 	if errs := u.Valid(); errs != nil {
-		return model.User{}, model.ErrInvalidUser
+		return model.User{}, errs
 	}
 	encPass, err := model.GenPass(u.Password)
 	if err != nil {
@@ -46,11 +78,33 @@ func (db *DB) CreateUser(u model.User) (model.User, error) {
 	return u, nil
 }
 
-func (db *DB) FindAllUser() ([]model.User, error) {
-	return model.OmitPass(db.users), nil
+func (db *DB) FindAllUser(ctx context.Context,
+	query storage.Query) ([]model.User, string, error) {
+
+	page, err := query.Page("since", "nick")
+	if err != nil {
+		return nil, "", err
+	}
+
+	found := model.OmitPass(db.users)
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch page.Sort {
+		case "since":
+			return ordered(page, compareTime(a.Since, b.Since))
+		case "nick":
+			return ordered(page, strings.Compare(a.Nick, b.Nick))
+		}
+		return false
+	})
+
+	start, end, next := window(page, len(found))
+	return found[start:end], next, nil
 }
 
-func (db *DB) UpdateUser(u model.User) (model.User, error) {
+func (db *DB) UpdateUser(ctx context.Context, u model.User) (model.User,
+	error) {
+
 	if u.Avatar != "" {
 		if err := u.ValidAvatar(); err != nil {
 			return model.User{}, err
@@ -62,6 +116,7 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 	for i, user := range db.users {
 		if u.ID == user.ID {
 			if u.Password != "" {
+				u.Email = user.Email
 				if err := u.ValidPassword(); err != nil {
 					return model.User{}, err
 				}
@@ -74,13 +129,15 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 			db.users[i].Nick = u.Nick
 			db.users[i].Avatar = u.Avatar
 			u.Password = ""
+			u.Role = user.Role
+			u.Verified = user.Verified
 			return u, nil
 		}
 	}
 	return model.User{}, storage.ErrUserNotFound
 }
 
-func (db *DB) DeleteUser(id int) error {
+func (db *DB) DeleteUser(ctx context.Context, id int) error {
 	index := -1
 	for i, user := range db.users {
 		if id == user.ID {
@@ -97,7 +154,24 @@ func (db *DB) DeleteUser(id int) error {
 	return nil
 }
 
-func (db *DB) FindUser(id int) (model.User, error) {
+func (db *DB) SetUserRole(ctx context.Context, id int, role string) (model.User,
+	error) {
+
+	if err := model.ValidRole(role); err != nil {
+		return model.User{}, err
+	}
+	for i, user := range db.users {
+		if id == user.ID {
+			db.users[i].Role = role
+			user = db.users[i]
+			user.Password = ""
+			return user, nil
+		}
+	}
+	return model.User{}, storage.ErrUserNotFound
+}
+
+func (db *DB) FindUser(ctx context.Context, id int) (model.User, error) {
 	for _, user := range db.users {
 		if id == user.ID {
 			user.Password = ""
@@ -107,18 +181,78 @@ func (db *DB) FindUser(id int) (model.User, error) {
 	return model.User{}, storage.ErrUserNotFound
 }
 
-func (db *DB) FindUserByEmail(email string) (model.User, error) {
+func (db *DB) FindUserByEmail(ctx context.Context, email string) (model.User,
+	error) {
+
 	for _, user := range db.users {
 		if email == user.Email {
+			user.Password = ""
 			return user, nil
 		}
 	}
 	return model.User{}, storage.ErrUserNotFound
 }
 
-func (db *DB) FindUserByNick(nick string) (model.User, error) {
+func (db *DB) FindUserByNick(ctx context.Context, nick string) (model.User,
+	error) {
+
 	for _, user := range db.users {
 		if nick == user.Nick {
+			user.Password = ""
+			return user, nil
+		}
+	}
+	return model.User{}, storage.ErrUserNotFound
+}
+
+func (db *DB) PasswordStamp(ctx context.Context, id int) (string, error) {
+	for _, user := range db.users {
+		if id == user.ID {
+			return model.PasswordStamp(user.Password), nil
+		}
+	}
+	return "", storage.ErrUserNotFound
+}
+
+func (db *DB) ResetPassword(ctx context.Context, id int, stamp,
+	password string) error {
+
+	for i, user := range db.users {
+		if id == user.ID {
+			if model.PasswordStamp(user.Password) != stamp {
+				return storage.ErrPasswordChanged
+			}
+			err := (model.User{Nick: user.Nick, Email: user.Email,
+				Password: password}).ValidPassword()
+			if err != nil {
+				return err
+			}
+			newPass, err := model.GenPass(password)
+			if err != nil {
+				return err
+			}
+			db.users[i].Password = newPass
+
+			// whoever knew the old password loses the sessions opened with it
+			for j, session := range db.sessions {
+				if session.UserID == id {
+					db.sessions[j].Revoked = true
+				}
+			}
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (db *DB) VerifyUser(ctx context.Context, id int,
+	email string) (model.User, error) {
+
+	for i, user := range db.users {
+		if id == user.ID && email == user.Email {
+			db.users[i].Verified = true
+			user = db.users[i]
+			user.Password = ""
 			return user, nil
 		}
 	}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CastVote(ctx context.Context, v model.Vote) (model.Vote, error) {
+	if err := v.Valid(); err != nil {
+		return model.Vote{}, err
+	}
+	if err := db.voteTargetExist(ctx, v.Target, v.TargetID); err != nil {
+		return model.Vote{}, err
+	}
+
+	index := -1
+	for i, vote := range db.votes {
+		if vote.UserID == v.UserID && vote.Target == v.Target &&
+			vote.TargetID == v.TargetID {
+			index = i
+			break
+		}
+	}
+
+	switch {
+	case index == -1:
+		db.lastVoteID++
+		v.ID = db.lastVoteID
+		v.When = time.Now()
+		db.votes = append(db.votes, v)
+	case db.votes[index].Direction == v.Direction:
+		v = db.votes[index]
+		v.Direction = 0
+		db.votes = append(db.votes[:index], db.votes[index+1:]...)
+	default:
+		db.votes[index].Direction = v.Direction
+		db.votes[index].When = time.Now()
+		v = db.votes[index]
+	}
+
+	db.countVotes(v.Target, v.TargetID)
+
+	return v, nil
+}
+
+func (db *DB) FindVote(ctx context.Context, user int, target string,
+	id int) (model.Vote, error) {
+
+	for _, vote := range db.votes {
+		if vote.UserID == user && vote.Target == target && vote.TargetID == id {
+			return vote, nil
+		}
+	}
+	return model.Vote{}, storage.ErrVoteNotFound
+}
+
+func (db *DB) FindVoteByUser(ctx context.Context, user int,
+	target string) ([]model.Vote, error) {
+
+	found := []model.Vote{}
+	for _, vote := range db.votes {
+		if vote.UserID == user && vote.Target == target {
+			found = append(found, vote)
+		}
+	}
+	return found, nil
+}
+
+func (db *DB) voteTargetExist(ctx context.Context, target string,
+	id int) error {
+
+	switch target {
+	case model.QuestionVote:
+		if _, err := db.FindQuestion(ctx, id); err != nil {
+			return err
+		}
+	case model.AnswerVote:
+		if _, err := db.FindAnswer(ctx, id); err != nil {
+			return err
+		}
+	case model.CommentVote:
+		if _, err := db.FindComment(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) countVotes(target string, id int) {
+	total := 0
+	for _, vote := range db.votes {
+		if vote.Target == target && vote.TargetID == id {
+			total += vote.Direction
+		}
+	}
+
+	switch target {
+	case model.QuestionVote:
+		for i := range db.questions {
+			if db.questions[i].ID == id {
+				db.questions[i].Votes = total
+			}
+		}
+	case model.AnswerVote:
+		for i := range db.answers {
+			if db.answers[i].ID == id {
+				db.answers[i].Votes = total
+			}
+		}
+	case model.CommentVote:
+		for i := range db.comments {
+			if db.comments[i].ID == id {
+				db.comments[i].Votes = total
+			}
+		}
+	}
+}
+
+// dropVotes removes the ballots of a deleted target
+func (db *DB) dropVotes(target string, id int) {
+	kept := db.votes[:0]
+	for _, vote := range db.votes {
+		if vote.Target != target || vote.TargetID != id {
+			kept = append(kept, vote)
+		}
+	}
+	db.votes = kept
+}
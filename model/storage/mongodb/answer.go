@@ -0,0 +1,175 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	question, err := db.FindQuestion(ctx, a.QuestionID)
+	if err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	if _, err := db.FindUser(ctx, a.UserID); err != nil {
+		return model.Answer{}, storage.ErrUserNotFound
+	}
+
+	a.When = time.Now()
+	a.LastEdit = time.Now()
+	a.QuestionID = question.ID
+	a.ContentHTML = markdown.Render(a.Content)
+	a.Votes = 0
+	a.Accepted = false
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+
+	a.ID = db.getID(db.GetAnswerC())
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).Insert(&a); err != nil {
+		return model.Answer{}, errors.Wrap(err, "cannot create new answer")
+	}
+
+	return a, nil
+}
+
+func (db *DB) UpdateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+	if _, err := db.FindQuestion(ctx, a.QuestionID); err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	answer, err := db.FindAnswer(ctx, a.ID)
+	if err != nil {
+		return model.Answer{}, storage.ErrAnswerNotFound
+	}
+
+	answer.Content = a.Content
+	answer.ContentHTML = markdown.Render(a.Content)
+	answer.LastEdit = time.Now()
+
+	// only the edited fields are set so a concurrent accept or vote is kept
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).UpdateId(answer.ID,
+		bson.M{"$set": bson.M{"content": answer.Content,
+			"content_html": answer.ContentHTML,
+			"last_edit":    answer.LastEdit}}); err != nil {
+		return model.Answer{}, errors.Wrap(err, "cannot update answer")
+	}
+
+	return answer, nil
+}
+
+func (db *DB) DeleteAnswer(ctx context.Context, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindAnswer(ctx, id); err != nil {
+		return storage.ErrAnswerNotFound
+	}
+
+	var comments []int
+	if err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).Find(bson.M{
+		"answer_id": id}).Distinct("_id", &comments); err != nil {
+		return errors.Wrap(err, "cannot enumerate comments")
+	}
+
+	if err := db.dropPosts(conn, model.CommentVote, db.GetCommentC(),
+		comments); err != nil {
+		return err
+	}
+	return db.dropPosts(conn, model.AnswerVote, db.GetAnswerC(), []int{id})
+}
+
+func (db *DB) FindAnswer(ctx context.Context, id int) (model.Answer, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	var answer model.Answer
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).FindId(id).One(&answer); err != nil {
+		return model.Answer{}, storage.ErrAnswerNotFound
+	}
+
+	return answer, nil
+}
+
+func (db *DB) FindAnswerByAuthor(ctx context.Context, id int) ([]model.Answer,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var answers []model.Answer
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).Find(bson.M{"user_id": id}).All(&answers); err != nil {
+		return nil, storage.ErrAnswerNotFound
+	}
+
+	return answers, nil
+}
+
+func (db *DB) FindAnswerByQuestion(ctx context.Context, id int) ([]model.Answer,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var answers []model.Answer
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).Find(bson.M{"question_id": id}).All(&answers); err != nil {
+		return nil, storage.ErrAnswerNotFound
+	}
+
+	return answers, nil
+}
+
+func (db *DB) AcceptAnswer(ctx context.Context, question, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindQuestion(ctx, question); err != nil {
+		return storage.ErrQuestionNotFound
+	}
+
+	// the new answer is accepted before the others are cleared, so racing
+	// accepts may leave none accepted but never two
+	answers := conn.DB(db.GetDatabase()).C(db.GetAnswerC())
+	if id != 0 {
+		err := answers.Update(bson.M{"_id": id, "question_id": question},
+			bson.M{"$set": bson.M{"accepted": true}})
+		if err == mgo.ErrNotFound {
+			return storage.ErrAnswerNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "cannot accept answer")
+		}
+	}
+	if _, err := answers.UpdateAll(bson.M{"question_id": question,
+		"_id": bson.M{"$ne": id}, "accepted": true},
+		bson.M{"$set": bson.M{"accepted": false}}); err != nil {
+		return errors.Wrap(err, "cannot clear accepted answer")
+	}
+
+	return nil
+}
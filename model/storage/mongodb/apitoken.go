@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAPIToken(ctx context.Context,
+	t model.APIToken) (model.APIToken, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	if err := t.Valid(); err != nil {
+		return model.APIToken{}, err
+	}
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.APIToken{}, storage.ErrUserNotFound
+	}
+
+	t.ID = db.getID(db.GetAPITokenC())
+	t.When = time.Now()
+	t.LastUsed = time.Time{}
+	if err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).
+		Insert(&t); err != nil {
+		return model.APIToken{}, errors.Wrap(err, "cannot create new API token")
+	}
+
+	return t, nil
+}
+
+func (db *DB) TouchAPIToken(ctx context.Context, id int,
+	when time.Time) error {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).UpdateId(id,
+		bson.M{"$set": bson.M{"last_used": when}})
+	if err == mgo.ErrNotFound {
+		return storage.ErrAPITokenNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot touch API token")
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteAPIToken(ctx context.Context, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return storage.ErrAPITokenNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot delete API token")
+	}
+
+	return nil
+}
+
+func (db *DB) FindAPIToken(ctx context.Context, id int) (model.APIToken,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var token model.APIToken
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).
+		FindId(id).One(&token); err != nil {
+		return model.APIToken{}, storage.ErrAPITokenNotFound
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindAPITokenByHash(ctx context.Context,
+	hash string) (model.APIToken, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var token model.APIToken
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).
+		Find(bson.M{"hash": hash}).One(&token); err != nil {
+		return model.APIToken{}, storage.ErrAPITokenNotFound
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindAPITokenByUser(ctx context.Context,
+	user int) ([]model.APIToken, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var tokens []model.APIToken
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetAPITokenC()).
+		Find(bson.M{"user_id": user}).Sort("_id").All(&tokens); err != nil {
+		return nil, errors.Wrap(err, "cannot enumerate API tokens")
+	}
+
+	return tokens, nil
+}
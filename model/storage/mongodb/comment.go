@@ -1,37 +1,46 @@
 package mongodb
 
 import (
-	"html"
+	"context"
 	"time"
 
-	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) CreateComment(c model.Comment) (model.Comment, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) CreateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
 
-	question, err := db.FindQuestion(c.QuestionID)
+	conn, release := db.session(ctx)
+	defer release()
+
+	question, err := db.FindQuestion(ctx, c.QuestionID)
 	if err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
 
-	if _, err := db.FindUser(c.UserID); err != nil {
+	if _, err := db.FindUser(ctx, c.UserID); err != nil {
 		return model.Comment{}, storage.ErrUserNotFound
 	}
 
+	if c.AnswerID != 0 {
+		answer, err := db.FindAnswer(ctx, c.AnswerID)
+		if err != nil || answer.QuestionID != question.ID {
+			return model.Comment{}, storage.ErrAnswerNotFound
+		}
+	}
+
 	c.When = time.Now()
 	c.LastEdit = time.Now()
 	c.QuestionID = question.ID
-	c.Content = html.EscapeString(c.Content)
+	c.ContentHTML = markdown.Render(c.Content)
 	c.Votes = 0
 
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
 
 	c.ID = db.getID(db.GetCommentC())
@@ -43,26 +52,29 @@ func (db *DB) CreateComment(c model.Comment) (model.Comment, error) {
 	return c, nil
 }
 
-func (db *DB) UpdateComment(c model.Comment) (model.Comment, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) UpdateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
-	if _, err := db.FindQuestion(c.QuestionID); err != nil {
+	if _, err := db.FindQuestion(ctx, c.QuestionID); err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
-	if _, err := db.FindUser(c.UserID); err != nil {
+	if _, err := db.FindUser(ctx, c.UserID); err != nil {
 		return model.Comment{}, storage.ErrUserNotFound
 	}
 
-	comment, err := db.FindComment(c.ID)
+	comment, err := db.FindComment(ctx, c.ID)
 	if err != nil {
 		return model.Comment{}, storage.ErrCommentNotFound
 	}
 
-	comment.Content = html.EscapeString(c.Content)
+	comment.Content = c.Content
+	comment.ContentHTML = markdown.Render(c.Content)
 	comment.LastEdit = time.Now()
 
 	if err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).UpdateId(comment.ID, &comment); err != nil {
@@ -72,9 +84,20 @@ func (db *DB) UpdateComment(c model.Comment) (model.Comment, error) {
 	return comment, nil
 }
 
-func (db *DB) FindComment(id int) (model.Comment, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) DeleteComment(ctx context.Context, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindComment(ctx, id); err != nil {
+		return storage.ErrCommentNotFound
+	}
+
+	return db.dropPosts(conn, model.CommentVote, db.GetCommentC(), []int{id})
+}
+
+func (db *DB) FindComment(ctx context.Context, id int) (model.Comment, error) {
+	conn, release := db.session(ctx)
+	defer release()
 
 	var comment model.Comment
 
@@ -85,9 +108,11 @@ func (db *DB) FindComment(id int) (model.Comment, error) {
 	return comment, nil
 }
 
-func (db *DB) FindCommentByAuthor(id int) ([]model.Comment, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindCommentByAuthor(ctx context.Context, id int) ([]model.Comment,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var comments []model.Comment
 
@@ -98,47 +123,54 @@ func (db *DB) FindCommentByAuthor(id int) ([]model.Comment, error) {
 	return comments, nil
 }
 
-func (db *DB) FindCommentByQuestion(id int) ([]model.Comment, error) {
-	conn := db.Copy()
-	defer conn.Close()
-
-	var comments []model.Comment
+// commentFields maps sortable names to comment document fields
+var commentFields = map[string]string{
+	"votes":     "votes",
+	"when":      "when",
+	"last_edit": "last_edit",
+}
 
-	if err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).Find(bson.M{"question_id": id}).All(&comments); err != nil {
-		return nil, storage.ErrCommentNotFound
-	}
+func (db *DB) FindCommentByQuestion(ctx context.Context, id int,
+	query storage.Query) ([]model.Comment, string, error) {
 
-	return comments, nil
-}
+	conn, release := db.session(ctx)
+	defer release()
 
-func (db *DB) UpComment(id int) error {
-	conn := db.Copy()
-	defer conn.Close()
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
+	}
 
-	change := mgo.Change{
-		Update:    bson.M{"$inc": bson.M{"votes": 1}},
-		ReturnNew: false,
+	// comments stored before answers existed have no answer_id
+	filter := bson.M{"question_id": id, "answer_id": bson.M{"$in": []interface{}{0, nil}}}
+	if query.Author != 0 {
+		filter["user_id"] = query.Author
 	}
 
-	if _, err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).FindId(id).Apply(change, nil); err != nil {
-		return storage.ErrCannotVote
+	var comments []model.Comment
+	if err := paginate(conn.DB(db.GetDatabase()).C(db.GetCommentC()).Find(filter),
+		page, commentFields).All(&comments); err != nil {
+		return nil, "", storage.ErrCommentNotFound
+	}
+	next := page.Next(len(comments))
+	if len(comments) > page.Limit {
+		comments = comments[:page.Limit]
 	}
 
-	return nil
+	return comments, next, nil
 }
 
-func (db *DB) DownComment(id int) error {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindCommentByAnswer(ctx context.Context, id int) ([]model.Comment,
+	error) {
 
-	change := mgo.Change{
-		Update:    bson.M{"$inc": bson.M{"votes": -1}},
-		ReturnNew: false,
-	}
+	conn, release := db.session(ctx)
+	defer release()
+
+	var comments []model.Comment
 
-	if _, err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).FindId(id).Apply(change, nil); err != nil {
-		return storage.ErrCannotVote
+	if err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).Find(bson.M{"answer_id": id}).All(&comments); err != nil {
+		return nil, storage.ErrCommentNotFound
 	}
 
-	return nil
+	return comments, nil
 }
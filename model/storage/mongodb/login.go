@@ -0,0 +1,123 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+var loginAttemptFields = map[string]string{
+	"when": "when",
+}
+
+func (db *DB) CreateLoginAttempt(ctx context.Context,
+	a model.LoginAttempt) (model.LoginAttempt, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	a.ID = db.getID(db.GetLoginAttemptC())
+	a.When = time.Now()
+	if err := conn.DB(db.GetDatabase()).C(db.GetLoginAttemptC()).
+		Insert(&a); err != nil {
+		return model.LoginAttempt{}, errors.Wrap(err,
+			"cannot create login attempt")
+	}
+
+	return a, nil
+}
+
+func (db *DB) FailLogin(ctx context.Context, key string,
+	window time.Duration) (model.LoginThrottle, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	c := conn.DB(db.GetDatabase()).C(db.GetLoginThrottleC())
+	now := time.Now()
+
+	// count on a recent throttle first, any other starts over
+	var t model.LoginThrottle
+	_, err := c.Find(bson.M{"_id": key, "last": bson.M{
+		"$gte": now.Add(-window)}}).Apply(mgo.Change{
+		Update: bson.M{"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"last": now}},
+		ReturnNew: true,
+	}, &t)
+	if err == nil {
+		return t, nil
+	}
+	if err != mgo.ErrNotFound {
+		return model.LoginThrottle{}, errors.Wrap(err, "cannot fail login")
+	}
+
+	t = model.LoginThrottle{ID: key, Failures: 1, Last: now}
+	if _, err := c.UpsertId(key, &t); err != nil {
+		return model.LoginThrottle{}, errors.Wrap(err, "cannot fail login")
+	}
+
+	return t, nil
+}
+
+func (db *DB) ClearLoginThrottle(ctx context.Context, key string) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetLoginThrottleC()).RemoveId(key)
+	if err != nil && err != mgo.ErrNotFound {
+		return errors.Wrap(err, "cannot clear login throttle")
+	}
+
+	return nil
+}
+
+func (db *DB) FindLoginAttempts(ctx context.Context, email string,
+	query storage.Query) ([]model.LoginAttempt, string, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	page, err := query.Page("when")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var attempts []model.LoginAttempt
+	if err := paginate(conn.DB(db.GetDatabase()).C(db.GetLoginAttemptC()).
+		Find(bson.M{"email": email}), page,
+		loginAttemptFields).All(&attempts); err != nil {
+		return nil, "", errors.Wrap(err, "cannot enumerate login attempts")
+	}
+	next := page.Next(len(attempts))
+	if len(attempts) > page.Limit {
+		attempts = attempts[:page.Limit]
+	}
+
+	return attempts, next, nil
+}
+
+func (db *DB) FindLoginThrottle(ctx context.Context,
+	key string) (model.LoginThrottle, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var t model.LoginThrottle
+
+	err := conn.DB(db.GetDatabase()).C(db.GetLoginThrottleC()).FindId(key).
+		One(&t)
+	if err == mgo.ErrNotFound {
+		return model.LoginThrottle{ID: key}, nil
+	}
+	if err != nil {
+		return model.LoginThrottle{}, errors.Wrap(err,
+			"cannot find login throttle")
+	}
+
+	return t, nil
+}
@@ -1,18 +1,30 @@
 package mongodb
 
 import (
+	"context"
 	"math/rand"
 	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 type DB struct {
-	userC     string
-	commentC  string
-	questionC string
-	database  string
+	userC      string
+	commentC   string
+	questionC  string
+	voteC      string
+	answerC    string
+	tagC       string
+	revisionC  string
+	sessionC   string
+	apiTokenC  string
+	twoFactorC string
+	attemptC   string
+	throttleC  string
+	database   string
 	*mgo.Session
 }
 
@@ -28,6 +40,42 @@ func (db *DB) GetQuestionC() string {
 	return db.questionC
 }
 
+func (db *DB) GetVoteC() string {
+	return db.voteC
+}
+
+func (db *DB) GetAnswerC() string {
+	return db.answerC
+}
+
+func (db *DB) GetTagC() string {
+	return db.tagC
+}
+
+func (db *DB) GetRevisionC() string {
+	return db.revisionC
+}
+
+func (db *DB) GetSessionC() string {
+	return db.sessionC
+}
+
+func (db *DB) GetAPITokenC() string {
+	return db.apiTokenC
+}
+
+func (db *DB) GetTwoFactorC() string {
+	return db.twoFactorC
+}
+
+func (db *DB) GetLoginAttemptC() string {
+	return db.attemptC
+}
+
+func (db *DB) GetLoginThrottleC() string {
+	return db.throttleC
+}
+
 func (db *DB) GetDatabase() string {
 	return db.database
 }
@@ -48,13 +96,124 @@ func (db *DB) getID(col string) int {
 	}
 }
 
-func New(URL, database, userC, questionC, commentC string) (*DB, error) {
+// session copies the session for a storage call, the copy is closed as soon
+// as ctx is done failing the pending operation and its socket timeout follows
+// the ctx deadline. release must be called once the call is over.
+func (db *DB) session(ctx context.Context) (*mgo.Session, func()) {
+	conn := db.Copy()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetSocketTimeout(time.Until(deadline))
+	}
+
+	over := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-over:
+		}
+		conn.Close()
+	}()
+
+	return conn, func() { close(over) }
+}
+
+// dropPosts removes posts of a kind from collection c with their votes and
+// revisions, ids can be given out again
+func (db *DB) dropPosts(conn *mgo.Session, kind, c string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := conn.DB(db.GetDatabase()).C(c).RemoveAll(bson.M{
+		"_id": bson.M{"$in": ids}}); err != nil {
+		return errors.Wrapf(err, "cannot delete %s", kind)
+	}
+	if _, err := conn.DB(db.GetDatabase()).C(db.GetVoteC()).RemoveAll(bson.M{
+		"target": kind, "target_id": bson.M{"$in": ids}}); err != nil {
+		return errors.Wrapf(err, "cannot delete %s votes", kind)
+	}
+	if _, err := conn.DB(db.GetDatabase()).C(db.GetRevisionC()).RemoveAll(
+		bson.M{"target": kind, "target_id": bson.M{"$in": ids}}); err != nil {
+		return errors.Wrapf(err, "cannot delete %s revisions", kind)
+	}
+	return nil
+}
+
+// paginate applies the page order and window to query, fields maps sortable
+// names to document fields and one extra document is fetched to know whether
+// a next page exists
+func paginate(query *mgo.Query, page storage.Page,
+	fields map[string]string) *mgo.Query {
+
+	sort := []string{}
+	if page.Sort != "" {
+		field := fields[page.Sort]
+		if page.Desc {
+			field = "-" + field
+		}
+		sort = append(sort, field)
+	}
+	sort = append(sort, "_id")
+
+	return query.Sort(sort...).Skip(page.Offset).Limit(page.Limit + 1)
+}
+
+func New(URL, database, userC, questionC, commentC, voteC, answerC, tagC,
+	revisionC, sessionC, apiTokenC, twoFactorC, attemptC,
+	throttleC string) (*DB, error) {
 	rand.Seed(time.Now().UnixNano())
 	db, err := mgo.Dial(URL)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot init mgo session")
 	}
-	return &DB{userC, commentC, questionC, database, db}, nil
+
+	ballot := mgo.Index{
+		Key:    []string{"user_id", "target", "target_id"},
+		Unique: true,
+	}
+	if err := db.DB(database).C(voteC).EnsureIndex(ballot); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create vote index")
+	}
+	revision := mgo.Index{
+		Key:    []string{"target", "target_id", "number"},
+		Unique: true,
+	}
+	if err := db.DB(database).C(revisionC).EnsureIndex(revision); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create revision index")
+	}
+	apiToken := mgo.Index{Key: []string{"hash"}, Unique: true}
+	if err := db.DB(database).C(apiTokenC).EnsureIndex(apiToken); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create API token index")
+	}
+	if err := db.DB(database).C(attemptC).EnsureIndexKey("email"); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create login attempt index")
+	}
+	if err := db.DB(database).C(questionC).EnsureIndexKey("tags"); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create question tags index")
+	}
+	// users created before e-mail verification existed keep posting
+	if _, err := db.DB(database).C(userC).UpdateAll(
+		bson.M{"verified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"verified": true}}); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot mark former users verified")
+	}
+	if err := ensureTextIndexes(db, database, map[string][]string{
+		questionC: {"title", "content"},
+		answerC:   {"content"},
+		commentC:  {"content"},
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{userC, commentC, questionC, voteC, answerC, tagC, revisionC,
+		sessionC, apiTokenC, twoFactorC, attemptC, throttleC, database,
+		db}, nil
 }
 
 func (db *DB) Close() error {
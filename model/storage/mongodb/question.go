@@ -1,49 +1,82 @@
 package mongodb
 
 import (
-	"html"
+	"context"
 	"time"
 
-	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 
 	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) FindAllQuestion() ([]model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+// questionFields maps sortable names to question document fields
+var questionFields = map[string]string{
+	"votes":     "votes",
+	"when":      "when",
+	"last_edit": "lastedit",
+}
+
+func (db *DB) FindAllQuestion(ctx context.Context,
+	query storage.Query) ([]model.Question, string, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
+	}
+
+	filter := bson.M{}
+	if query.Author != 0 {
+		filter["user_id"] = query.Author
+	}
+	if tags := db.resolveTags(ctx, query.Tags); len(tags) > 0 {
+		filter["tags"] = bson.M{"$all": tags}
+	}
 
 	var questions []model.Question
-	if err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).Find(nil).All(&questions); err != nil {
-		return nil, err
+	if err := paginate(conn.DB(db.GetDatabase()).C(db.GetQuestionC()).Find(filter),
+		page, questionFields).All(&questions); err != nil {
+		return nil, "", err
 	}
-	return questions, nil
+	next := page.Next(len(questions))
+	if len(questions) > page.Limit {
+		questions = questions[:page.Limit]
+	}
+
+	return questions, next, nil
 }
 
-func (db *DB) CreateQuestion(q model.Question) (model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) CreateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
 
-	if _, err := db.FindQuestionByTitle(q.Title); err == nil {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindQuestionByTitle(ctx, q.Title); err == nil {
 		return model.Question{}, storage.ErrQuestionAlreadyExist
 	}
 
-	if _, err := db.FindUser(q.UserID); err != nil {
+	if _, err := db.FindUser(ctx, q.UserID); err != nil {
 		return model.Question{}, storage.ErrUserNotFound
 	}
 
 	q.When = time.Now()
 	q.LastEdit = time.Now()
 	q.Votes = 0
-	q.Title = html.EscapeString(q.Title)
-	q.Content = html.EscapeString(q.Content)
+	q.ContentHTML = markdown.Render(q.Content)
+	q.Tags = db.resolveTags(ctx, q.Tags)
 
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
+	if err := db.registerTags(ctx, q.Tags); err != nil {
+		return model.Question{}, err
+	}
 
 	q.ID = db.getID(db.GetQuestionC())
 
@@ -54,21 +87,33 @@ func (db *DB) CreateQuestion(q model.Question) (model.Question, error) {
 	return q, nil
 }
 
-func (db *DB) UpdateQuestion(q model.Question) (model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) UpdateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
 
+	conn, release := db.session(ctx)
+	defer release()
+
+	if q.Tags != nil {
+		q.Tags = db.resolveTags(ctx, q.Tags)
+	}
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
 
-	question, err := db.FindQuestion(q.ID)
+	question, err := db.FindQuestion(ctx, q.ID)
 	if err != nil {
 		return model.Question{}, storage.ErrQuestionNotFound
 	}
 
-	question.Title = html.EscapeString(q.Title)
-	question.Content = html.EscapeString(q.Content)
+	if q.Tags != nil {
+		if err := db.registerTags(ctx, q.Tags); err != nil {
+			return model.Question{}, err
+		}
+		question.Tags = q.Tags
+	}
+	question.Title = q.Title
+	question.Content = q.Content
+	question.ContentHTML = markdown.Render(q.Content)
 	question.LastEdit = time.Now()
 	if err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).UpdateId(question.ID, &question); err != nil {
 		return model.Question{}, errors.Wrap(err, "cannot update question")
@@ -77,9 +122,40 @@ func (db *DB) UpdateQuestion(q model.Question) (model.Question, error) {
 	return question, nil
 }
 
-func (db *DB) FindQuestion(id int) (model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) DeleteQuestion(ctx context.Context, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindQuestion(ctx, id); err != nil {
+		return storage.ErrQuestionNotFound
+	}
+
+	var answers, comments []int
+	if err := conn.DB(db.GetDatabase()).C(db.GetAnswerC()).Find(bson.M{
+		"question_id": id}).Distinct("_id", &answers); err != nil {
+		return errors.Wrap(err, "cannot enumerate answers")
+	}
+	if err := conn.DB(db.GetDatabase()).C(db.GetCommentC()).Find(bson.M{
+		"question_id": id}).Distinct("_id", &comments); err != nil {
+		return errors.Wrap(err, "cannot enumerate comments")
+	}
+
+	if err := db.dropPosts(conn, model.CommentVote, db.GetCommentC(),
+		comments); err != nil {
+		return err
+	}
+	if err := db.dropPosts(conn, model.AnswerVote, db.GetAnswerC(),
+		answers); err != nil {
+		return err
+	}
+	return db.dropPosts(conn, model.QuestionVote, db.GetQuestionC(), []int{id})
+}
+
+func (db *DB) FindQuestion(ctx context.Context, id int) (model.Question,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var question model.Question
 
@@ -90,9 +166,11 @@ func (db *DB) FindQuestion(id int) (model.Question, error) {
 	return question, nil
 }
 
-func (db *DB) FindQuestionByTitle(title string) (model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindQuestionByTitle(ctx context.Context,
+	title string) (model.Question, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var question model.Question
 
@@ -103,9 +181,11 @@ func (db *DB) FindQuestionByTitle(title string) (model.Question, error) {
 	return question, nil
 }
 
-func (db *DB) FindQuestionByAuthor(author int) ([]model.Question, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindQuestionByAuthor(ctx context.Context,
+	author int) ([]model.Question, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var questions []model.Question
 
@@ -115,35 +195,3 @@ func (db *DB) FindQuestionByAuthor(author int) ([]model.Question, error) {
 
 	return questions, nil
 }
-
-func (db *DB) UpQuestion(id int) error {
-	conn := db.Copy()
-	defer conn.Close()
-
-	change := mgo.Change{
-		Update:    bson.M{"$inc": bson.M{"votes": 1}},
-		ReturnNew: false,
-	}
-
-	if _, err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).FindId(id).Apply(change, nil); err != nil {
-		return storage.ErrCannotVote
-	}
-
-	return nil
-}
-
-func (db *DB) DownQuestion(id int) error {
-	conn := db.Copy()
-	defer conn.Close()
-
-	change := mgo.Change{
-		Update:    bson.M{"$inc": bson.M{"votes": -1}},
-		ReturnNew: false,
-	}
-
-	if _, err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).FindId(id).Apply(change, nil); err != nil {
-		return storage.ErrCannotVote
-	}
-
-	return nil
-}
@@ -0,0 +1,75 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateRevision(ctx context.Context,
+	r model.Revision) (model.Revision, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	if err := r.Valid(); err != nil {
+		return model.Revision{}, err
+	}
+	if r.When.IsZero() {
+		r.When = time.Now()
+	}
+
+	revisions := conn.DB(db.GetDatabase()).C(db.GetRevisionC())
+	var last model.Revision
+	err := revisions.Find(bson.M{"target": r.Target, "target_id": r.TargetID}).
+		Sort("-number").One(&last)
+	if err != nil && err != mgo.ErrNotFound {
+		return model.Revision{}, errors.Wrap(err, "cannot number revision")
+	}
+	r.Number = last.Number + 1
+	r.ID = db.getID(db.GetRevisionC())
+
+	// the unique index rejects a concurrent revision with the same number
+	if err := revisions.Insert(&r); err != nil {
+		return model.Revision{}, errors.Wrap(err, "cannot create new revision")
+	}
+
+	return r, nil
+}
+
+func (db *DB) FindRevision(ctx context.Context, target string, id,
+	number int) (model.Revision, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var revision model.Revision
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetRevisionC()).Find(bson.M{
+		"target": target, "target_id": id, "number": number}).One(&revision); err != nil {
+		return model.Revision{}, storage.ErrRevisionNotFound
+	}
+
+	return revision, nil
+}
+
+func (db *DB) FindRevisionByTarget(ctx context.Context, target string,
+	id int) ([]model.Revision, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var revisions []model.Revision
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetRevisionC()).Find(bson.M{
+		"target": target, "target_id": id}).Sort("number").All(&revisions); err != nil {
+		return nil, errors.Wrap(err, "cannot enumerate revisions")
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,114 @@
+package mongodb
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// scored is a text search match of any searchable collection
+type scored struct {
+	ID         int     `bson:"_id"`
+	QuestionID int     `bson:"question_id"`
+	Title      string  `bson:"title"`
+	Content    string  `bson:"content"`
+	Score      float64 `bson:"score"`
+}
+
+func (db *DB) Search(ctx context.Context, search string,
+	query storage.Query) ([]model.SearchResult, string, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	page, err := query.Page()
+	if err != nil {
+		return nil, "", err
+	}
+	terms := storage.Terms(search)
+	if len(terms) == 0 {
+		return nil, "", storage.ErrEmptySearch
+	}
+	// quoted terms are matched as phrases and joined by AND
+	text := `"` + strings.Join(terms, `" "`) + `"`
+
+	// every collection ranks on its own, the best of each are merged
+	results := []model.SearchResult{}
+	for kind, col := range map[string]string{
+		model.QuestionVote: db.GetQuestionC(),
+		model.AnswerVote:   db.GetAnswerC(),
+		model.CommentVote:  db.GetCommentC(),
+	} {
+		var docs []scored
+		if err := conn.DB(db.GetDatabase()).C(col).
+			Find(bson.M{"$text": bson.M{"$search": text}}).
+			Select(bson.M{"score": bson.M{"$meta": "textScore"},
+				"question_id": 1, "title": 1, "content": 1}).
+			Sort("$textScore:score").
+			Limit(page.Offset + page.Limit + 1).All(&docs); err != nil {
+			return nil, "", errors.Wrapf(err, "cannot search %s", col)
+		}
+
+		for _, doc := range docs {
+			result := model.SearchResult{
+				Kind:       kind,
+				ID:         doc.ID,
+				QuestionID: doc.QuestionID,
+				Title:      doc.Title,
+				Rank:       doc.Score,
+			}
+			if kind == model.QuestionVote {
+				result.QuestionID = doc.ID
+			}
+			snippet, found := storage.Snippet(doc.Content, terms)
+			if !found && doc.Title != "" {
+				snippet, _ = storage.Snippet(doc.Title, terms)
+			}
+			result.Snippet = snippet
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ID < b.ID
+	})
+
+	start := page.Offset
+	if start > len(results) {
+		start = len(results)
+	}
+	end := start + page.Limit
+	if end > len(results) {
+		end = len(results)
+	}
+	return results[start:end], page.Next(len(results) - start), nil
+}
+
+// ensureTextIndexes creates the text indexes used by Search
+func ensureTextIndexes(session *mgo.Session, database string,
+	fields map[string][]string) error {
+
+	for col, keys := range fields {
+		index := mgo.Index{Name: "search"}
+		for _, key := range keys {
+			index.Key = append(index.Key, "$text:"+key)
+		}
+		if err := session.DB(database).C(col).EnsureIndex(index); err != nil {
+			return errors.Wrapf(err, "cannot create %s text index", col)
+		}
+	}
+	return nil
+}
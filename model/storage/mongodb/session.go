@@ -0,0 +1,91 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateSession(ctx context.Context,
+	s model.Session) (model.Session, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	s.When = time.Now()
+	if err := conn.DB(db.GetDatabase()).C(db.GetSessionC()).
+		Insert(&s); err != nil {
+		return model.Session{}, errors.Wrap(err, "cannot create new session")
+	}
+
+	return s, nil
+}
+
+func (db *DB) RotateSession(ctx context.Context, id, old,
+	token string) (model.Session, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	c := conn.DB(db.GetDatabase()).C(db.GetSessionC())
+
+	var session model.Session
+	if err := c.FindId(id).One(&session); err != nil {
+		return model.Session{}, storage.ErrSessionNotFound
+	}
+
+	// the token condition makes the swap atomic against a concurrent refresh,
+	// which also keeps the rotated hashes read above current
+	_, err := c.Find(bson.M{
+		"_id": id, "token": old, "revoked": false,
+		"expires": bson.M{"$gt": time.Now()}}).Apply(mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"token": token, "rotated": session.Retire()}},
+		ReturnNew: true,
+	}, &session)
+	if err == mgo.ErrNotFound {
+		return model.Session{}, storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return model.Session{}, errors.Wrap(err, "cannot rotate session")
+	}
+
+	return session, nil
+}
+
+func (db *DB) RevokeSession(ctx context.Context, id string) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetSessionC()).UpdateId(id,
+		bson.M{"$set": bson.M{"revoked": true}})
+	if err == mgo.ErrNotFound {
+		return storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot revoke session")
+	}
+
+	return nil
+}
+
+func (db *DB) FindSession(ctx context.Context, id string) (model.Session,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var session model.Session
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetSessionC()).
+		FindId(id).One(&session); err != nil {
+		return model.Session{}, storage.ErrSessionNotFound
+	}
+
+	return session, nil
+}
@@ -0,0 +1,144 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) FindAllTag(ctx context.Context) ([]model.Tag, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	var tags []model.Tag
+	if err := conn.DB(db.GetDatabase()).C(db.GetTagC()).Find(nil).All(&tags); err != nil {
+		return nil, errors.Wrap(err, "cannot enumerate tags")
+	}
+
+	var counts []struct {
+		Tag   string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	pipeline := []bson.M{
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+	}
+	if err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).Pipe(pipeline).All(&counts); err != nil {
+		return nil, errors.Wrap(err, "cannot count tags")
+	}
+	count := map[string]int{}
+	for _, c := range counts {
+		count[c.Tag] = c.Count
+	}
+	for i := range tags {
+		tags[i].Count = count[tags[i].Name]
+	}
+
+	return tags, nil
+}
+
+func (db *DB) UpdateTag(ctx context.Context, t model.Tag) (model.Tag, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	tag, err := db.FindTag(ctx, t.Name)
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	tag.Description = t.Description
+	if err := tag.Valid(); err != nil {
+		return model.Tag{}, err
+	}
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetTagC()).UpdateId(tag.Name,
+		bson.M{"$set": bson.M{"description": tag.Description}}); err != nil {
+		return model.Tag{}, errors.Wrap(err, "cannot update tag")
+	}
+
+	return tag, nil
+}
+
+func (db *DB) FindTag(ctx context.Context, name string) (model.Tag, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	var tag model.Tag
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetTagC()).FindId(model.NormalizeTag(name)).One(&tag); err != nil {
+		return model.Tag{}, storage.ErrTagNotFound
+	}
+	count, err := conn.DB(db.GetDatabase()).C(db.GetQuestionC()).Find(bson.M{"tags": tag.Name}).Count()
+	if err != nil {
+		return model.Tag{}, errors.Wrap(err, "cannot count tag")
+	}
+	tag.Count = count
+
+	return tag, nil
+}
+
+func (db *DB) MergeTag(ctx context.Context, from, to string) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	from, to = model.NormalizeTag(from), model.NormalizeTag(to)
+	if _, err := db.FindTag(ctx, from); err != nil {
+		return err
+	}
+	to = db.resolveTags(ctx, []string{to})[0]
+	if from == to {
+		return model.ErrInvalidTag
+	}
+	if err := model.ValidTagName(to); err != nil {
+		return err
+	}
+	if err := db.registerTags(ctx, []string{to}); err != nil {
+		return err
+	}
+
+	questions := conn.DB(db.GetDatabase()).C(db.GetQuestionC())
+	if _, err := questions.UpdateAll(bson.M{"tags": from},
+		bson.M{"$addToSet": bson.M{"tags": to}}); err != nil {
+		return errors.Wrap(err, "cannot retag questions")
+	}
+	if _, err := questions.UpdateAll(bson.M{"tags": from},
+		bson.M{"$pull": bson.M{"tags": from}}); err != nil {
+		return errors.Wrap(err, "cannot retag questions")
+	}
+
+	if _, err := conn.DB(db.GetDatabase()).C(db.GetTagC()).UpdateAll(
+		bson.M{"$or": []bson.M{{"_id": from}, {"synonym_of": from}}},
+		bson.M{"$set": bson.M{"synonym_of": to}}); err != nil {
+		return errors.Wrap(err, "cannot merge tag")
+	}
+
+	return nil
+}
+
+// resolveTags normalizes names replacing synonyms by their canonical tag
+func (db *DB) resolveTags(ctx context.Context, names []string) []string {
+	tags := model.NormalizeTags(names)
+	for i, name := range tags {
+		if tag, err := db.FindTag(ctx, name); err == nil && tag.SynonymOf != "" {
+			tags[i] = tag.SynonymOf
+		}
+	}
+	return model.NormalizeTags(tags)
+}
+
+// registerTags adds unknown tags to the catalogue
+func (db *DB) registerTags(ctx context.Context, names []string) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	for _, name := range names {
+		err := conn.DB(db.GetDatabase()).C(db.GetTagC()).Insert(&model.Tag{Name: name})
+		if err != nil && !mgo.IsDup(err) {
+			return errors.Wrapf(err, "cannot create tag %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) SaveTwoFactor(ctx context.Context,
+	t model.TwoFactor) (model.TwoFactor, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.TwoFactor{}, storage.ErrUserNotFound
+	}
+
+	t.When = time.Now()
+	if _, err := conn.DB(db.GetDatabase()).C(db.GetTwoFactorC()).
+		UpsertId(t.UserID, &t); err != nil {
+		return model.TwoFactor{}, errors.Wrap(err,
+			"cannot save two-factor authentication")
+	}
+
+	return t, nil
+}
+
+func (db *DB) UpdateTwoFactor(ctx context.Context, old,
+	t model.TwoFactor) error {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetTwoFactorC()).Update(bson.M{
+		"_id": old.UserID, "last_step": old.LastStep,
+		"recovery": old.Recovery}, bson.M{"$set": bson.M{
+		"secret": t.Secret, "enabled": t.Enabled, "last_step": t.LastStep,
+		"recovery": t.Recovery}})
+	if err == mgo.ErrNotFound {
+		return storage.ErrTwoFactorNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot update two-factor authentication")
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteTwoFactor(ctx context.Context, user int) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetTwoFactorC()).RemoveId(user)
+	if err == mgo.ErrNotFound {
+		return storage.ErrTwoFactorNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot delete two-factor authentication")
+	}
+
+	return nil
+}
+
+func (db *DB) FindTwoFactor(ctx context.Context, user int) (model.TwoFactor,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var t model.TwoFactor
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetTwoFactorC()).
+		FindId(user).One(&t); err != nil {
+		return model.TwoFactor{}, storage.ErrTwoFactorNotFound
+	}
+
+	return t, nil
+}
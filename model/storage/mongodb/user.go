@@ -1,41 +1,66 @@
 package mongodb
 
 import (
+	"context"
 	"html"
 	"time"
 
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) FindAllUser() ([]model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+// userFields maps sortable names to user document fields
+var userFields = map[string]string{
+	"since": "since",
+	"nick":  "nick",
+}
+
+func (db *DB) FindAllUser(ctx context.Context,
+	query storage.Query) ([]model.User, string, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	page, err := query.Page("since", "nick")
+	if err != nil {
+		return nil, "", err
+	}
 
 	var users []model.User
-	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).Find(nil).All(&users); err != nil {
-		return nil, errors.Wrap(err, "cannot enumerate users")
+	if err := paginate(conn.DB(db.GetDatabase()).C(db.GetUserC()).Find(nil),
+		page, userFields).All(&users); err != nil {
+		return nil, "", errors.Wrap(err, "cannot enumerate users")
+	}
+	next := page.Next(len(users))
+	if len(users) > page.Limit {
+		users = users[:page.Limit]
 	}
-	return model.OmitPass(users), nil
+
+	return model.OmitPass(users), next, nil
 }
 
-func (db *DB) CreateUser(u model.User) (model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) CreateUser(ctx context.Context, u model.User) (model.User,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
-	if _, err := db.FindUserByNick(u.Nick); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+	if _, err := db.FindUserByNick(ctx, u.Nick); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
-	if _, err := db.FindUserByEmail(u.Email); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+	if _, err := db.FindUserByEmail(ctx, u.Email); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
 
 	u.Since = time.Now()
+	u.Role = model.RoleUser
+	u.Verified = false
 	if errs := u.Valid(); errs != nil {
-		return model.User{}, errors.Errorf("Cannot create user: %s", errs)
+		return model.User{}, errors.Wrap(errs, "Cannot create user")
 	}
 	encPass, err := model.GenPass(u.Password)
 	if err != nil {
@@ -53,16 +78,21 @@ func (db *DB) CreateUser(u model.User) (model.User, error) {
 	return u, nil
 }
 
-func (db *DB) UpdateUser(u model.User) (model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) UpdateUser(ctx context.Context, u model.User) (model.User,
+	error) {
 
-	user, err := db.FindUser(u.ID)
+	conn, release := db.session(ctx)
+	defer release()
+
+	user, err := db.FindUser(ctx, u.ID)
 	if err != nil {
 		return model.User{}, storage.ErrUserNotFound
 	}
 
+	// FindUser omits the password hash, only the changed fields are written
+	set := bson.M{"nick": u.Nick, "avatar": html.EscapeString(u.Avatar)}
 	if u.Password != "" {
+		u.Email = user.Email
 		if errs := u.ValidPassword(); errs != nil {
 			return model.User{}, errs
 		}
@@ -70,7 +100,7 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 		if err != nil {
 			return model.User{}, err
 		}
-		user.Password = newPass
+		set["password"] = newPass
 	}
 
 	if errs := u.ValidNick(); errs != nil {
@@ -80,28 +110,52 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 		return model.User{}, errs
 	}
 
-	user.Nick = u.Nick
-	user.Avatar = html.EscapeString(u.Avatar)
-
-	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).UpdateId(user.ID, &user); err != nil {
+	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).UpdateId(user.ID,
+		bson.M{"$set": set}); err != nil {
 		return model.User{}, errors.Wrapf(err, "cannot update user: %s", user.Nick)
 	}
 	u.Password = ""
 	u.Since = user.Since
+	u.Role = user.Role
+	u.Verified = user.Verified
 
 	return u, nil
 }
 
-func (db *DB) DeleteUser(id int) error {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) DeleteUser(ctx context.Context, id int) error {
+	conn, release := db.session(ctx)
+	defer release()
 
 	return conn.DB(db.GetDatabase()).C(db.GetUserC()).RemoveId(id)
 }
 
-func (db *DB) FindUser(id int) (model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) SetUserRole(ctx context.Context, id int, role string) (model.User,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	if err := model.ValidRole(role); err != nil {
+		return model.User{}, err
+	}
+	user, err := db.FindUser(ctx, id)
+	if err != nil {
+		return model.User{}, storage.ErrUserNotFound
+	}
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).UpdateId(id,
+		bson.M{"$set": bson.M{"role": role}}); err != nil {
+		return model.User{}, errors.Wrapf(err, "cannot set role of user: %s",
+			user.Nick)
+	}
+	user.Role = role
+
+	return user, nil
+}
+
+func (db *DB) FindUser(ctx context.Context, id int) (model.User, error) {
+	conn, release := db.session(ctx)
+	defer release()
 
 	var user model.User
 
@@ -112,9 +166,11 @@ func (db *DB) FindUser(id int) (model.User, error) {
 	return user, nil
 }
 
-func (db *DB) FindUserByNick(nick string) (model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindUserByNick(ctx context.Context, nick string) (model.User,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var user model.User
 
@@ -125,27 +181,131 @@ func (db *DB) FindUserByNick(nick string) (model.User, error) {
 	return user, nil
 }
 
-func (db *DB) FindUserByEmail(email string) (model.User, error) {
-	conn := db.Copy()
-	defer conn.Close()
+func (db *DB) FindUserByEmail(ctx context.Context, email string) (model.User,
+	error) {
+
+	conn, release := db.session(ctx)
+	defer release()
 
 	var user model.User
 
 	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).Find(bson.M{"email": email}).One(&user); err != nil {
 		return model.User{}, storage.ErrUserNotFound
 	}
-
+	user.Password = ""
 	return user, nil
 }
 
-func (db *DB) Login(login string, pass string) error {
-	user, err := db.FindUserByEmail(login)
+func (db *DB) Login(ctx context.Context, login string, pass string) error {
+	conn, release := db.session(ctx)
+	defer release()
+
+	// FindUserByEmail omits the password hash the comparison needs
+	var user model.User
+	err := conn.DB(db.GetDatabase()).C(db.GetUserC()).
+		Find(bson.M{"email": login}).One(&user)
+	if err != nil {
+		password.CompareDummy([]byte(pass))
+		return storage.ErrInvalidCredentials
+	}
+	if err := password.Compare([]byte(user.Password), []byte(pass)); err != nil {
+		return storage.ErrInvalidCredentials
+	}
+	if password.NeedsRehash([]byte(user.Password)) {
+		db.rehash(ctx, user, pass)
+	}
+	return nil
+}
+
+// rehash upgrades the password hash of user to argon2id with the current policy
+// while the password is at hand, the old hash keeps working when it fails
+func (db *DB) rehash(ctx context.Context, user model.User, pass string) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	newPass, err := model.GenPass(pass)
+	if err != nil {
+		return
+	}
+	// a password changed meanwhile is kept
+	conn.DB(db.GetDatabase()).C(db.GetUserC()).Update(
+		bson.M{"_id": user.ID, "password": user.Password},
+		bson.M{"$set": bson.M{"password": newPass}})
+}
+
+func (db *DB) PasswordStamp(ctx context.Context, id int) (string, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	var user model.User
+	if err := conn.DB(db.GetDatabase()).C(db.GetUserC()).FindId(id).
+		One(&user); err != nil {
+		return "", storage.ErrUserNotFound
+	}
+	return model.PasswordStamp(user.Password), nil
+}
+
+func (db *DB) ResetPassword(ctx context.Context, id int, stamp,
+	password string) error {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	c := conn.DB(db.GetDatabase()).C(db.GetUserC())
+	var user model.User
+	if err := c.FindId(id).One(&user); err != nil {
+		return storage.ErrUserNotFound
+	}
+	if model.PasswordStamp(user.Password) != stamp {
+		return storage.ErrPasswordChanged
+	}
+	err := (model.User{Nick: user.Nick, Email: user.Email,
+		Password: password}).ValidPassword()
 	if err != nil {
-		argon2.CompareHashAndPassword([]byte("not found"), []byte(pass))
-		return errors.Errorf("user or pass invalid")
+		return err
 	}
-	if err := argon2.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
-		return errors.Errorf("user or pass invalid")
+	newPass, err := model.GenPass(password)
+	if err != nil {
+		return err
 	}
+
+	// the old hash condition keeps a concurrent reset from being overwritten
+	err = c.Update(bson.M{"_id": id, "password": user.Password},
+		bson.M{"$set": bson.M{"password": newPass}})
+	if err == mgo.ErrNotFound {
+		return storage.ErrPasswordChanged
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot reset password of user: %s",
+			user.Nick)
+	}
+
+	// whoever knew the old password loses the sessions opened with it
+	_, err = conn.DB(db.GetDatabase()).C(db.GetSessionC()).UpdateAll(
+		bson.M{"user_id": id}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return errors.Wrapf(err, "cannot revoke sessions of user: %s",
+			user.Nick)
+	}
+
 	return nil
 }
+
+func (db *DB) VerifyUser(ctx context.Context, id int,
+	email string) (model.User, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	err := conn.DB(db.GetDatabase()).C(db.GetUserC()).Update(
+		bson.M{"_id": id, "email": email},
+		bson.M{"$set": bson.M{"verified": true}})
+	if err == mgo.ErrNotFound {
+		return model.User{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return model.User{}, errors.Wrap(err, "cannot verify user")
+	}
+
+	return db.FindUser(ctx, id)
+}
@@ -0,0 +1,126 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CastVote(ctx context.Context, v model.Vote) (model.Vote, error) {
+	conn, release := db.session(ctx)
+	defer release()
+
+	if err := v.Valid(); err != nil {
+		return model.Vote{}, err
+	}
+	targetC, err := db.voteTargetC(ctx, v.Target, v.TargetID)
+	if err != nil {
+		return model.Vote{}, err
+	}
+
+	votes := conn.DB(db.GetDatabase()).C(db.GetVoteC())
+	ballot := bson.M{"user_id": v.UserID, "target": v.Target,
+		"target_id": v.TargetID}
+
+	var vote model.Vote
+	err = votes.Find(ballot).One(&vote)
+	switch {
+	case err == mgo.ErrNotFound:
+		v.ID = db.getID(db.GetVoteC())
+		v.When = time.Now()
+		err = votes.Insert(&v)
+	case err != nil:
+	case vote.Direction == v.Direction:
+		v = vote
+		v.Direction = 0
+		err = votes.RemoveId(vote.ID)
+	default:
+		vote.Direction = v.Direction
+		vote.When = time.Now()
+		v = vote
+		err = votes.UpdateId(vote.ID, &vote)
+	}
+	if err != nil {
+		return model.Vote{}, errors.Wrap(storage.ErrCannotVote, err.Error())
+	}
+
+	var total struct {
+		Sum int `bson:"sum"`
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"target": v.Target, "target_id": v.TargetID}},
+		{"$group": bson.M{"_id": nil, "sum": bson.M{"$sum": "$direction"}}},
+	}
+	err = votes.Pipe(pipeline).One(&total)
+	if err != nil && err != mgo.ErrNotFound {
+		return model.Vote{}, errors.Wrap(storage.ErrCannotVote, err.Error())
+	}
+
+	if err := conn.DB(db.GetDatabase()).C(targetC).UpdateId(v.TargetID,
+		bson.M{"$set": bson.M{"votes": total.Sum}}); err != nil {
+		return model.Vote{}, errors.Wrap(storage.ErrCannotVote, err.Error())
+	}
+
+	return v, nil
+}
+
+func (db *DB) FindVote(ctx context.Context, user int, target string,
+	id int) (model.Vote, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var vote model.Vote
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetVoteC()).Find(bson.M{
+		"user_id": user, "target": target, "target_id": id}).One(&vote); err != nil {
+		return model.Vote{}, storage.ErrVoteNotFound
+	}
+
+	return vote, nil
+}
+
+func (db *DB) FindVoteByUser(ctx context.Context, user int,
+	target string) ([]model.Vote, error) {
+
+	conn, release := db.session(ctx)
+	defer release()
+
+	var votes []model.Vote
+
+	if err := conn.DB(db.GetDatabase()).C(db.GetVoteC()).Find(bson.M{
+		"user_id": user, "target": target}).All(&votes); err != nil {
+		return nil, errors.Wrap(err, "cannot enumerate votes")
+	}
+
+	return votes, nil
+}
+
+// voteTargetC returns the collection whose votes field must follow the ballots
+func (db *DB) voteTargetC(ctx context.Context, target string, id int) (string,
+	error) {
+
+	switch target {
+	case model.QuestionVote:
+		if _, err := db.FindQuestion(ctx, id); err != nil {
+			return "", err
+		}
+		return db.GetQuestionC(), nil
+	case model.AnswerVote:
+		if _, err := db.FindAnswer(ctx, id); err != nil {
+			return "", err
+		}
+		return db.GetAnswerC(), nil
+	case model.CommentVote:
+		if _, err := db.FindComment(ctx, id); err != nil {
+			return "", err
+		}
+		return db.GetCommentC(), nil
+	}
+	return "", model.ErrInvalidVote
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidQuery = errors.New("Invalid query")
+
+// Query bounds, filters and orders listings. Sort is one of the fields
+// accepted by each listing (since or nick for users; votes, when or last_edit
+// for questions and comments), prefixed by "-" for descending order. Listings
+// return the cursor of the next page, empty on the last one, to be sent back
+// as Cursor. Tags keeps questions carrying every tag.
+type Query struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Author int
+	Tags   []string
+}
+
+// Page is a validated Query ready to be applied by a storage backend
+type Page struct {
+	Offset int
+	Limit  int
+	Sort   string
+	Desc   bool
+}
+
+// Page validates the query against the sortable fields of a listing
+func (q Query) Page(sortable ...string) (Page, error) {
+	page := Page{Limit: q.Limit}
+	if page.Limit == 0 {
+		page.Limit = DefaultLimit
+	}
+	if page.Limit < 0 || page.Limit > MaxLimit {
+		return Page{}, ErrInvalidQuery
+	}
+
+	if q.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil || !strings.HasPrefix(string(raw), "o:") {
+			return Page{}, ErrInvalidQuery
+		}
+		page.Offset, err = strconv.Atoi(string(raw[len("o:"):]))
+		if err != nil || page.Offset < 0 {
+			return Page{}, ErrInvalidQuery
+		}
+	}
+
+	if q.Sort != "" {
+		page.Sort = strings.TrimPrefix(q.Sort, "-")
+		page.Desc = page.Sort != q.Sort
+		valid := false
+		for _, field := range sortable {
+			if page.Sort == field {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return Page{}, ErrInvalidQuery
+		}
+	}
+
+	return page, nil
+}
+
+// Next returns the cursor of the following page given how many items were
+// fetched, backends fetch Limit+1 items so an empty page is never announced
+func (p Page) Next(fetched int) string {
+	if fetched <= p.Limit {
+		return ""
+	}
+	offset := "o:" + strconv.Itoa(p.Offset+p.Limit)
+	return base64.RawURLEncoding.EncodeToString([]byte(offset))
+}
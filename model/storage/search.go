@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultSnippetSize = 160
+	markOpen           = "<mark>"
+	markClose          = "</mark>"
+
+	// MarkStart and MarkEnd surround the terms of database made snippets
+	MarkStart = "\x02"
+	MarkEnd   = "\x03"
+)
+
+var ErrEmptySearch = errors.New("Empty search")
+
+// Terms splits a search or a document in lowercase words of at least two
+// letters or digits
+func Terms(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) > 1 {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// Snippet cuts the text around the first term found marking every term
+// occurrence with <mark>, it reports whether any term was found. The text is
+// escaped so the snippet is HTML.
+func Snippet(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	start := -1
+	for i := range lower {
+		if wordStart(lower, i) && matchAt(lower, i, terms) > 0 {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		start = 0
+	}
+
+	from := start - defaultSnippetSize/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + defaultSnippetSize
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var snippet strings.Builder
+	marked := false
+	if from > 0 {
+		snippet.WriteString("…")
+	}
+	for i := from; i < to; {
+		if n := matchAt(lower, i, terms); n > 0 && wordStart(lower, i) {
+			snippet.WriteString(markOpen)
+			snippet.WriteString(html.EscapeString(string(runes[i : i+n])))
+			snippet.WriteString(markClose)
+			marked = true
+			i += n
+			continue
+		}
+		snippet.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if to < len(runes) {
+		snippet.WriteString("…")
+	}
+	return snippet.String(), marked
+}
+
+// Marked escapes a database made snippet for HTML and turns its MarkStart
+// and MarkEnd around terms into <mark>
+func Marked(snippet string) string {
+	return strings.NewReplacer(MarkStart, markOpen, MarkEnd, markClose).
+		Replace(html.EscapeString(snippet))
+}
+
+// matchAt returns the length of the term starting at i, 0 if none
+func matchAt(lower []rune, i int, terms []string) int {
+	for _, term := range terms {
+		t := []rune(term)
+		if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == term {
+			return len(t)
+		}
+	}
+	return 0
+}
+
+func wordStart(lower []rune, i int) bool {
+	return i == 0 || !unicode.IsLetter(lower[i-1]) && !unicode.IsDigit(lower[i-1])
+}
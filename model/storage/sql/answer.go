@@ -0,0 +1,189 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	db = db.with(ctx)
+
+	question, err := db.FindQuestion(ctx, a.QuestionID)
+	if err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	if _, err := db.FindUser(ctx, a.UserID); err != nil {
+		return model.Answer{}, storage.ErrUserNotFound
+	}
+
+	a.When = time.Now()
+	a.LastEdit = time.Now()
+	a.QuestionID = question.ID
+	a.ContentHTML = markdown.Render(a.Content)
+	a.Votes = 0
+	a.Accepted = false
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+
+	tx := db.Begin()
+	if err := tx.Create(&a).Error; err != nil {
+		tx.Rollback()
+		return model.Answer{}, err
+	}
+	if err := indexDoc(tx, model.AnswerVote, a.ID, a.QuestionID, "",
+		a.Content); err != nil {
+		tx.Rollback()
+		return model.Answer{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return model.Answer{}, err
+	}
+
+	return a, nil
+}
+
+func (db *DB) UpdateAnswer(ctx context.Context, a model.Answer) (model.Answer,
+	error) {
+
+	db = db.with(ctx)
+
+	if err := a.Valid(); err != nil {
+		return model.Answer{}, err
+	}
+	if _, err := db.FindQuestion(ctx, a.QuestionID); err != nil {
+		return model.Answer{}, storage.ErrQuestionNotFound
+	}
+
+	answer, err := db.FindAnswer(ctx, a.ID)
+	if err != nil {
+		return model.Answer{}, storage.ErrAnswerNotFound
+	}
+
+	answer.Content = a.Content
+	answer.ContentHTML = markdown.Render(a.Content)
+	answer.LastEdit = time.Now()
+
+	tx := db.Begin()
+	if err := tx.Save(&answer).Error; err != nil {
+		tx.Rollback()
+		return model.Answer{}, err
+	}
+	if err := indexDoc(tx, model.AnswerVote, answer.ID, answer.QuestionID, "",
+		answer.Content); err != nil {
+		tx.Rollback()
+		return model.Answer{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return model.Answer{}, err
+	}
+
+	return answer, nil
+}
+
+func (db *DB) DeleteAnswer(ctx context.Context, id int) error {
+	db = db.with(ctx)
+
+	if _, err := db.FindAnswer(ctx, id); err != nil {
+		return storage.ErrAnswerNotFound
+	}
+
+	var comments []int
+	if err := db.Model(&model.Comment{}).Where("answer_id = ?", id).
+		Pluck("id", &comments).Error; err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if err := dropPosts(tx, model.CommentVote, &model.Comment{},
+		comments); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := dropPosts(tx, model.AnswerVote, &model.Answer{},
+		[]int{id}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (db *DB) FindAnswer(ctx context.Context, id int) (model.Answer, error) {
+	db = db.with(ctx)
+
+	var answer model.Answer
+
+	if err := db.First(&answer, id).Error; err != nil {
+		return model.Answer{}, storage.ErrAnswerNotFound
+	}
+
+	return answer, nil
+}
+
+func (db *DB) FindAnswerByAuthor(ctx context.Context, id int) ([]model.Answer,
+	error) {
+
+	db = db.with(ctx)
+
+	var answers []model.Answer
+
+	if err := db.Where("user_id = ?", id).Find(&answers).Error; err != nil {
+		return nil, storage.ErrAnswerNotFound
+	}
+
+	return answers, nil
+}
+
+func (db *DB) FindAnswerByQuestion(ctx context.Context, id int) ([]model.Answer,
+	error) {
+
+	db = db.with(ctx)
+
+	var answers []model.Answer
+
+	if err := db.Where("question_id = ?", id).Find(&answers).Error; err != nil {
+		return nil, storage.ErrAnswerNotFound
+	}
+
+	return answers, nil
+}
+
+func (db *DB) AcceptAnswer(ctx context.Context, question, id int) error {
+	db = db.with(ctx)
+
+	if _, err := db.FindQuestion(ctx, question); err != nil {
+		return storage.ErrQuestionNotFound
+	}
+	if id != 0 {
+		answer, err := db.FindAnswer(ctx, id)
+		if err != nil || answer.QuestionID != question {
+			return storage.ErrAnswerNotFound
+		}
+	}
+
+	tx := db.Begin()
+	if err := tx.Model(&model.Answer{}).Where("question_id = ?", question).
+		UpdateColumn("accepted", false).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if id != 0 {
+		if err := tx.Model(&model.Answer{}).Where("id = ?", id).
+			UpdateColumn("accepted", true).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
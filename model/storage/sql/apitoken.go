@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateAPIToken(ctx context.Context,
+	t model.APIToken) (model.APIToken, error) {
+
+	db = db.with(ctx)
+
+	if err := t.Valid(); err != nil {
+		return model.APIToken{}, err
+	}
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.APIToken{}, storage.ErrUserNotFound
+	}
+
+	t.ID = 0
+	t.When = time.Now()
+	t.LastUsed = time.Time{}
+	if err := db.Create(&t).Error; err != nil {
+		return model.APIToken{}, err
+	}
+
+	return t, nil
+}
+
+func (db *DB) TouchAPIToken(ctx context.Context, id int,
+	when time.Time) error {
+
+	db = db.with(ctx)
+
+	update := db.Model(&model.APIToken{}).Where("id = ?", id).
+		UpdateColumn("last_used", when)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return storage.ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteAPIToken(ctx context.Context, id int) error {
+	db = db.with(ctx)
+
+	remove := db.Where("id = ?", id).Delete(&model.APIToken{})
+	if remove.Error != nil {
+		return remove.Error
+	}
+	if remove.RowsAffected == 0 {
+		return storage.ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) FindAPIToken(ctx context.Context, id int) (model.APIToken,
+	error) {
+
+	db = db.with(ctx)
+
+	var token model.APIToken
+
+	if err := db.First(&token, id).Error; err != nil {
+		return model.APIToken{}, storage.ErrAPITokenNotFound
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindAPITokenByHash(ctx context.Context,
+	hash string) (model.APIToken, error) {
+
+	db = db.with(ctx)
+
+	var token model.APIToken
+
+	if err := db.Where("hash = ?", hash).First(&token).Error; err != nil {
+		return model.APIToken{}, storage.ErrAPITokenNotFound
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindAPITokenByUser(ctx context.Context,
+	user int) ([]model.APIToken, error) {
+
+	db = db.with(ctx)
+
+	var tokens []model.APIToken
+
+	if err := db.Where("user_id = ?", user).Order("id").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
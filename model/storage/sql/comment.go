@@ -1,67 +1,122 @@
 package sql
 
 import (
-	"html"
+	"context"
 	"time"
 
-	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) CreateComment(c model.Comment) (model.Comment, error) {
-	question, err := db.FindQuestion(c.QuestionID)
+func (db *DB) CreateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
+
+	db = db.with(ctx)
+
+	question, err := db.FindQuestion(ctx, c.QuestionID)
 	if err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
 
-	if _, err := db.FindUser(c.UserID); err != nil {
+	if _, err := db.FindUser(ctx, c.UserID); err != nil {
 		return model.Comment{}, storage.ErrUserNotFound
 	}
 
+	if c.AnswerID != 0 {
+		answer, err := db.FindAnswer(ctx, c.AnswerID)
+		if err != nil || answer.QuestionID != question.ID {
+			return model.Comment{}, storage.ErrAnswerNotFound
+		}
+	}
+
 	c.When = time.Now()
 	c.LastEdit = time.Now()
 	c.QuestionID = question.ID
-	c.Content = html.EscapeString(c.Content)
+	c.ContentHTML = markdown.Render(c.Content)
 	c.Votes = 0
 
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
 
-	if err := db.Create(&c).Error; err != nil {
+	tx := db.Begin()
+	if err := tx.Create(&c).Error; err != nil {
+		tx.Rollback()
+		return model.Comment{}, err
+	}
+	if err := indexDoc(tx, model.CommentVote, c.ID, c.QuestionID, "",
+		c.Content); err != nil {
+		tx.Rollback()
+		return model.Comment{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
 		return model.Comment{}, err
 	}
 
 	return c, nil
 }
-func (db *DB) UpdateComment(c model.Comment) (model.Comment, error) {
+func (db *DB) UpdateComment(ctx context.Context,
+	c model.Comment) (model.Comment, error) {
+
+	db = db.with(ctx)
+
 	if err := c.Valid(); err != nil {
-		return model.Comment{}, model.ErrInvalidComment
+		return model.Comment{}, err
 	}
-	if _, err := db.FindQuestion(c.QuestionID); err != nil {
+	if _, err := db.FindQuestion(ctx, c.QuestionID); err != nil {
 		return model.Comment{}, storage.ErrQuestionNotFound
 	}
-	if _, err := db.FindUser(c.UserID); err != nil {
+	if _, err := db.FindUser(ctx, c.UserID); err != nil {
 		return model.Comment{}, storage.ErrUserNotFound
 	}
 
-	comment, err := db.FindComment(c.ID)
+	comment, err := db.FindComment(ctx, c.ID)
 	if err != nil {
 		return model.Comment{}, storage.ErrCommentNotFound
 	}
 
-	comment.Content = html.EscapeString(c.Content)
+	comment.Content = c.Content
+	comment.ContentHTML = markdown.Render(c.Content)
 	comment.LastEdit = time.Now()
 
-	if err := db.Save(&comment).Error; err != nil {
+	tx := db.Begin()
+	if err := tx.Save(&comment).Error; err != nil {
+		tx.Rollback()
+		return model.Comment{}, err
+	}
+	if err := indexDoc(tx, model.CommentVote, comment.ID, comment.QuestionID,
+		"", comment.Content); err != nil {
+		tx.Rollback()
+		return model.Comment{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
 		return model.Comment{}, err
 	}
 
 	return comment, nil
 }
-func (db *DB) FindComment(id int) (model.Comment, error) {
+func (db *DB) DeleteComment(ctx context.Context, id int) error {
+	db = db.with(ctx)
+
+	if _, err := db.FindComment(ctx, id); err != nil {
+		return storage.ErrCommentNotFound
+	}
+
+	tx := db.Begin()
+	if err := dropPosts(tx, model.CommentVote, &model.Comment{},
+		[]int{id}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (db *DB) FindComment(ctx context.Context, id int) (model.Comment, error) {
+	db = db.with(ctx)
+
 	var comment model.Comment
 
 	if err := db.First(&comment, id).Error; err != nil {
@@ -70,7 +125,11 @@ func (db *DB) FindComment(id int) (model.Comment, error) {
 
 	return comment, nil
 }
-func (db *DB) FindCommentByAuthor(id int) ([]model.Comment, error) {
+func (db *DB) FindCommentByAuthor(ctx context.Context, id int) ([]model.Comment,
+	error) {
+
+	db = db.with(ctx)
+
 	var comment []model.Comment
 
 	if err := db.Where("user_id = ?").Find(&comment).Error; err != nil {
@@ -80,35 +139,43 @@ func (db *DB) FindCommentByAuthor(id int) ([]model.Comment, error) {
 	return comment, nil
 }
 
-func (db *DB) FindCommentByQuestion(id int) ([]model.Comment, error) {
-	var comment []model.Comment
+func (db *DB) FindCommentByQuestion(ctx context.Context, id int,
+	query storage.Query) ([]model.Comment, string, error) {
 
-	if err := db.Where("question_id = ?", id).Find(&comment).Error; err != nil {
-		return nil, storage.ErrCommentNotFound
+	db = db.with(ctx)
+
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
 	}
 
-	return comment, nil
+	scope := db.Where("question_id = ? AND answer_id = 0", id)
+	if query.Author != 0 {
+		scope = scope.Where("user_id = ?", query.Author)
+	}
 
-}
-func (db *DB) UpComment(id int) error {
-	comment, err := db.FindComment(id)
-	if err != nil {
-		return storage.ErrQuestionNotFound
+	var comment []model.Comment
+	if err := paginate(scope, page).Find(&comment).Error; err != nil {
+		return nil, "", storage.ErrCommentNotFound
 	}
-	if err := db.Model(&comment).UpdateColumn("votes",
-		gorm.Expr("votes + ?", 1)).Error; err != nil {
-		return storage.ErrCannotVote
+	next := page.Next(len(comment))
+	if len(comment) > page.Limit {
+		comment = comment[:page.Limit]
 	}
-	return nil
+
+	return comment, next, nil
 }
-func (db *DB) DownComment(id int) error {
-	comment, err := db.FindComment(id)
-	if err != nil {
-		return storage.ErrQuestionNotFound
-	}
-	if err := db.Model(&comment).UpdateColumn("votes",
-		gorm.Expr("votes + ?", 1)).Error; err != nil {
-		return storage.ErrCannotVote
+
+func (db *DB) FindCommentByAnswer(ctx context.Context, id int) ([]model.Comment,
+	error) {
+
+	db = db.with(ctx)
+
+	var comment []model.Comment
+
+	if err := db.Where("answer_id = ?", id).Find(&comment).Error; err != nil {
+		return nil, storage.ErrCommentNotFound
 	}
-	return nil
+
+	return comment, nil
 }
@@ -0,0 +1,92 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// failLogin counts a failure in a single statement so concurrent failures are
+// all counted
+const failLogin = `INSERT INTO login_throttles (id, failures, "last")
+VALUES (?, 1, ?)
+ON CONFLICT (id) DO UPDATE SET
+	failures = CASE WHEN "last" < ? THEN 1 ELSE failures + 1 END,
+	"last" = excluded."last"`
+
+func (db *DB) CreateLoginAttempt(ctx context.Context,
+	a model.LoginAttempt) (model.LoginAttempt, error) {
+
+	db = db.with(ctx)
+
+	a.When = time.Now()
+	if err := db.Create(&a).Error; err != nil {
+		return model.LoginAttempt{}, err
+	}
+
+	return a, nil
+}
+
+func (db *DB) FailLogin(ctx context.Context, key string,
+	window time.Duration) (model.LoginThrottle, error) {
+
+	db = db.with(ctx)
+
+	now := time.Now()
+	if err := db.Exec(failLogin, key, now, now.Add(-window)).
+		Error; err != nil {
+		return model.LoginThrottle{}, err
+	}
+
+	return db.FindLoginThrottle(ctx, key)
+}
+
+func (db *DB) ClearLoginThrottle(ctx context.Context, key string) error {
+	db = db.with(ctx)
+
+	return db.Where("id = ?", key).Delete(&model.LoginThrottle{}).Error
+}
+
+func (db *DB) FindLoginAttempts(ctx context.Context, email string,
+	query storage.Query) ([]model.LoginAttempt, string, error) {
+
+	db = db.with(ctx)
+
+	page, err := query.Page("when")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var attempts []model.LoginAttempt
+	if err := paginate(db.Where("email = ?", email), page).
+		Find(&attempts).Error; err != nil {
+		return nil, "", err
+	}
+	next := page.Next(len(attempts))
+	if len(attempts) > page.Limit {
+		attempts = attempts[:page.Limit]
+	}
+
+	return attempts, next, nil
+}
+
+func (db *DB) FindLoginThrottle(ctx context.Context,
+	key string) (model.LoginThrottle, error) {
+
+	db = db.with(ctx)
+
+	var t model.LoginThrottle
+
+	scope := db.Where("id = ?", key).First(&t)
+	if scope.RecordNotFound() {
+		return model.LoginThrottle{ID: key}, nil
+	}
+	if scope.Error != nil {
+		return model.LoginThrottle{}, scope.Error
+	}
+
+	return t, nil
+}
@@ -1,119 +1,234 @@
 package sql
 
 import (
-	"html"
+	"context"
 	"time"
 
-	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/markdown"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) FindAllQuestion() ([]model.Question, error) {
+func (db *DB) FindAllQuestion(ctx context.Context,
+	query storage.Query) ([]model.Question, string, error) {
+
+	db = db.with(ctx)
+
+	page, err := query.Page("votes", "when", "last_edit")
+	if err != nil {
+		return nil, "", err
+	}
+
+	scope := db.DB
+	if query.Author != 0 {
+		scope = scope.Where("user_id = ?", query.Author)
+	}
+	if tags := db.resolveTags(ctx, query.Tags); len(tags) > 0 {
+		tagged := db.Model(&questionTag{}).Select("question_id").
+			Where("tag IN (?)", tags).Group("question_id").
+			Having("COUNT(*) = ?", len(tags))
+		scope = scope.Where("id IN (?)", tagged.SubQuery())
+	}
+
 	var questions []model.Question
-	if err := db.Find(&questions).Error; err != nil {
-		return nil, err
+	if err := paginate(scope, page).Find(&questions).Error; err != nil {
+		return nil, "", err
+	}
+	next := page.Next(len(questions))
+	if len(questions) > page.Limit {
+		questions = questions[:page.Limit]
 	}
-	return questions, nil
+	if err := db.loadTags(ctx, questions); err != nil {
+		return nil, "", err
+	}
+
+	return questions, next, nil
 }
 
-func (db *DB) CreateQuestion(q model.Question) (model.Question, error) {
-	if _, err := db.FindQuestionByTitle(q.Title); err == nil {
+func (db *DB) CreateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
+
+	db = db.with(ctx)
+
+	if _, err := db.FindQuestionByTitle(ctx, q.Title); err == nil {
 		return model.Question{}, storage.ErrQuestionAlreadyExist
 	}
 
-	if _, err := db.FindUser(q.UserID); err != nil {
+	if _, err := db.FindUser(ctx, q.UserID); err != nil {
 		return model.Question{}, storage.ErrUserNotFound
 	}
 
 	q.When = time.Now()
 	q.LastEdit = time.Now()
 	q.Votes = 0
-	q.Title = html.EscapeString(q.Title)
-	q.Content = html.EscapeString(q.Content)
+	q.ContentHTML = markdown.Render(q.Content)
+	q.Tags = db.resolveTags(ctx, q.Tags)
 
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
 
-	if err := db.Create(&q).Error; err != nil {
+	tx := db.Begin()
+	if err := tx.Create(&q).Error; err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := saveTags(tx, q.ID, q.Tags); err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := indexDoc(tx, model.QuestionVote, q.ID, q.ID, q.Title,
+		q.Content); err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
 		return model.Question{}, err
 	}
 
 	return q, nil
 }
 
-func (db *DB) UpdateQuestion(q model.Question) (model.Question, error) {
+func (db *DB) UpdateQuestion(ctx context.Context,
+	q model.Question) (model.Question, error) {
+
+	db = db.with(ctx)
+
+	if q.Tags != nil {
+		q.Tags = db.resolveTags(ctx, q.Tags)
+	}
 	if err := q.Valid(); err != nil {
 		return model.Question{}, err
 	}
 
-	question, err := db.FindQuestion(q.ID)
+	question, err := db.FindQuestion(ctx, q.ID)
 	if err != nil {
 		return model.Question{}, storage.ErrQuestionNotFound
 	}
 
-	question.Title = html.EscapeString(q.Title)
-	question.Content = html.EscapeString(q.Content)
+	question.Title = q.Title
+	question.Content = q.Content
+	question.ContentHTML = markdown.Render(q.Content)
 	question.LastEdit = time.Now()
-	if err := db.Save(&question).Error; err != nil {
+	if q.Tags != nil {
+		question.Tags = q.Tags
+	}
+
+	tx := db.Begin()
+	if err := tx.Save(&question).Error; err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := saveTags(tx, question.ID, question.Tags); err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := indexDoc(tx, model.QuestionVote, question.ID, question.ID,
+		question.Title, question.Content); err != nil {
+		tx.Rollback()
+		return model.Question{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
 		return model.Question{}, err
 	}
 
 	return question, nil
 }
 
-func (db *DB) FindQuestion(id int) (model.Question, error) {
+func (db *DB) DeleteQuestion(ctx context.Context, id int) error {
+	db = db.with(ctx)
+
+	if _, err := db.FindQuestion(ctx, id); err != nil {
+		return storage.ErrQuestionNotFound
+	}
+
+	var answers, comments []int
+	if err := db.Model(&model.Answer{}).Where("question_id = ?", id).
+		Pluck("id", &answers).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.Comment{}).Where("question_id = ?", id).
+		Pluck("id", &comments).Error; err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if err := dropPosts(tx, model.CommentVote, &model.Comment{},
+		comments); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := dropPosts(tx, model.AnswerVote, &model.Answer{},
+		answers); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := dropPosts(tx, model.QuestionVote, &model.Question{},
+		[]int{id}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("question_id = ?", id).
+		Delete(&questionTag{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (db *DB) FindQuestion(ctx context.Context, id int) (model.Question,
+	error) {
+
+	db = db.with(ctx)
+
 	var question model.Question
 
 	if err := db.First(&question, id).Error; err != nil {
 		return model.Question{}, storage.ErrQuestionNotFound
 	}
 
-	return question, nil
+	questions := []model.Question{question}
+	if err := db.loadTags(ctx, questions); err != nil {
+		return model.Question{}, err
+	}
+
+	return questions[0], nil
 }
 
-func (db *DB) FindQuestionByTitle(title string) (model.Question, error) {
+func (db *DB) FindQuestionByTitle(ctx context.Context,
+	title string) (model.Question, error) {
+
+	db = db.with(ctx)
+
 	var question model.Question
 
 	if err := db.Where("title = ?", title).First(&question).Error; err != nil {
 		return model.Question{}, storage.ErrQuestionNotFound
 	}
 
-	return question, nil
+	questions := []model.Question{question}
+	if err := db.loadTags(ctx, questions); err != nil {
+		return model.Question{}, err
+	}
+
+	return questions[0], nil
 }
 
-func (db *DB) FindQuestionByAuthor(author int) ([]model.Question, error) {
+func (db *DB) FindQuestionByAuthor(ctx context.Context,
+	author int) ([]model.Question, error) {
+
+	db = db.with(ctx)
+
 	var question []model.Question
 
 	if err := db.Where("user_id = ?", author).Find(&question).Error; err != nil {
 		return nil, storage.ErrQuestionNotFound
 	}
-
-	return question, nil
-}
-
-func (db *DB) UpQuestion(id int) error {
-	question, err := db.FindQuestion(id)
-	if err != nil {
-		return storage.ErrQuestionNotFound
-	}
-	if err := db.Model(&question).UpdateColumn("votes",
-		gorm.Expr("votes + ?", 1)).Error; err != nil {
-		return storage.ErrCannotVote
+	if err := db.loadTags(ctx, question); err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (db *DB) DownQuestion(id int) error {
-	question, err := db.FindQuestion(id)
-	if err != nil {
-		return storage.ErrQuestionNotFound
-	}
-	if err := db.Model(&question).UpdateColumn("votes",
-		gorm.Expr("votes - ?", 1)).Error; err != nil {
-		return storage.ErrCannotVote
-	}
-	return nil
+	return question, nil
 }
@@ -0,0 +1,74 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateRevision(ctx context.Context,
+	r model.Revision) (model.Revision, error) {
+
+	db = db.with(ctx)
+
+	if err := r.Valid(); err != nil {
+		return model.Revision{}, err
+	}
+	if r.When.IsZero() {
+		r.When = time.Now()
+	}
+
+	tx := db.Begin()
+	var last struct{ Number int }
+	if err := tx.Model(&model.Revision{}).
+		Select("COALESCE(MAX(number), 0) AS number").
+		Where("target = ? AND target_id = ?", r.Target, r.TargetID).
+		Scan(&last).Error; err != nil {
+		tx.Rollback()
+		return model.Revision{}, err
+	}
+	r.Number = last.Number + 1
+
+	if err := tx.Create(&r).Error; err != nil {
+		tx.Rollback()
+		return model.Revision{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return model.Revision{}, err
+	}
+
+	return r, nil
+}
+
+func (db *DB) FindRevision(ctx context.Context, target string, id,
+	number int) (model.Revision, error) {
+
+	db = db.with(ctx)
+
+	var revision model.Revision
+
+	if err := db.Where("target = ? AND target_id = ? AND number = ?", target,
+		id, number).First(&revision).Error; err != nil {
+		return model.Revision{}, storage.ErrRevisionNotFound
+	}
+
+	return revision, nil
+}
+
+func (db *DB) FindRevisionByTarget(ctx context.Context, target string,
+	id int) ([]model.Revision, error) {
+
+	db = db.with(ctx)
+
+	var revisions []model.Revision
+
+	if err := db.Where("target = ? AND target_id = ?", target, id).
+		Order("number").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,82 @@
+package sql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// ErrNoFTS5 is returned by Migrate when the linked sqlite lacks FTS5
+var ErrNoFTS5 = errors.New(
+	"sqlite built without FTS5, build with -tags sqlite_fts5")
+
+// search_index is an FTS5 table, sqlite must be built with the sqlite_fts5 tag
+const createSearchIndex = `CREATE VIRTUAL TABLE IF NOT EXISTS search_index
+	USING fts5(kind UNINDEXED, ref_id UNINDEXED, question_id UNINDEXED, title,
+	content)`
+
+// hasFTS5 reports whether the linked sqlite was compiled with FTS5
+func (db *DB) hasFTS5() (bool, error) {
+	var used bool
+	row := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Row()
+	if err := row.Scan(&used); err != nil {
+		return false, err
+	}
+	return used, nil
+}
+
+func (db *DB) Search(ctx context.Context, search string,
+	query storage.Query) ([]model.SearchResult, string, error) {
+
+	db = db.with(ctx)
+
+	page, err := query.Page()
+	if err != nil {
+		return nil, "", err
+	}
+	terms := storage.Terms(search)
+	if len(terms) == 0 {
+		return nil, "", storage.ErrEmptySearch
+	}
+	// quoted terms are matched literally and implicitly joined by AND
+	match := `"` + strings.Join(terms, `" "`) + `"`
+
+	// char(2) and char(3) are the storage.MarkStart and MarkEnd sentinels
+	var results []model.SearchResult
+	if err := db.Raw(`SELECT kind, ref_id AS id, question_id, title,
+		snippet(search_index, -1, char(2), char(3), '…', 24) AS snippet,
+		-bm25(search_index) AS rank
+		FROM search_index WHERE search_index MATCH ?
+		ORDER BY bm25(search_index), kind, ref_id LIMIT ? OFFSET ?`,
+		match, page.Limit+1, page.Offset).Scan(&results).Error; err != nil {
+		return nil, "", err
+	}
+	next := page.Next(len(results))
+	if len(results) > page.Limit {
+		results = results[:page.Limit]
+	}
+	// contents are stored as typed, only the marks are markup
+	for i := range results {
+		results[i].Snippet = storage.Marked(results[i].Snippet)
+	}
+
+	return results, next, nil
+}
+
+// indexDoc replaces the full-text entry of a question, answer or comment
+func indexDoc(tx *gorm.DB, kind string, id, question int, title,
+	content string) error {
+
+	if err := tx.Exec("DELETE FROM search_index WHERE kind = ? AND ref_id = ?",
+		kind, id).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO search_index (kind, ref_id, question_id, title,
+		content) VALUES (?, ?, ?, ?, ?)`, kind, id, question, title,
+		content).Error
+}
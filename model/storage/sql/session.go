@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CreateSession(ctx context.Context,
+	s model.Session) (model.Session, error) {
+
+	db = db.with(ctx)
+
+	s.When = time.Now()
+	if err := db.Create(&s).Error; err != nil {
+		return model.Session{}, err
+	}
+
+	return s, nil
+}
+
+func (db *DB) RotateSession(ctx context.Context, id, old,
+	token string) (model.Session, error) {
+
+	db = db.with(ctx)
+
+	session, err := db.FindSession(ctx, id)
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	// the token condition makes the swap atomic against a concurrent refresh,
+	// which also keeps the rotated hashes read above current
+	update := db.Model(&model.Session{}).
+		Where("id = ? AND token = ? AND revoked = ? AND expires > ?", id, old,
+			false, time.Now()).
+		UpdateColumns(map[string]interface{}{
+			"token":   token,
+			"rotated": session.Retire(),
+		})
+	if update.Error != nil {
+		return model.Session{}, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return model.Session{}, storage.ErrSessionNotFound
+	}
+
+	return db.FindSession(ctx, id)
+}
+
+func (db *DB) RevokeSession(ctx context.Context, id string) error {
+	db = db.with(ctx)
+
+	update := db.Model(&model.Session{}).Where("id = ?", id).
+		UpdateColumn("revoked", true)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return storage.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) FindSession(ctx context.Context, id string) (model.Session,
+	error) {
+
+	db = db.with(ctx)
+
+	var session model.Session
+
+	if err := db.Where("id = ?", id).First(&session).Error; err != nil {
+		return model.Session{}, storage.ErrSessionNotFound
+	}
+
+	return session, nil
+}
@@ -1,12 +1,22 @@
 package sql
 
 import (
+	"context"
+	"database/sql"
+	"sync"
+
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
+// DB bound stores the gorm handle of each pending request context, shared by
+// every storage call of the request
 type DB struct {
 	*gorm.DB
+	conn  *sql.DB
+	bound *sync.Map
 }
 
 func New(dbpath string) (*DB, error) {
@@ -14,7 +24,125 @@ func New(dbpath string) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DB{db}, nil
+	return &DB{db, db.DB(), &sync.Map{}}, nil
+}
+
+// ctxConn runs the statements issued by gorm under a request context, the
+// driver interrupts them and transactions roll back once it is done
+type ctxConn struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (c ctxConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.PrepareContext(c.ctx, query)
+}
+
+func (c ctxConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.QueryContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Begin() (*sql.Tx, error) {
+	return c.BeginTx(c.ctx, nil)
+}
+
+// BeginTx ignores ctx, gorm begins every transaction with the background
+// context
+func (c ctxConn) BeginTx(ctx context.Context,
+	opts *sql.TxOptions) (*sql.Tx, error) {
+
+	return c.DB.BeginTx(c.ctx, opts)
+}
+
+// with returns the storage bound to ctx, every storage method starts with it.
+// The handle is made once per context and forgotten when the context is done.
+func (db *DB) with(ctx context.Context) *DB {
+	if handle, ok := db.bound.Load(ctx); ok {
+		return &DB{handle.(*gorm.DB), db.conn, db.bound}
+	}
+	handle, err := gorm.Open("sqlite3", ctxConn{db.conn, ctx})
+	if err != nil {
+		return &DB{db.DB, db.conn, db.bound}
+	}
+	// contexts never done, such as the background one, are not kept
+	if ctx.Done() != nil {
+		if stored, loaded := db.bound.LoadOrStore(ctx, handle); loaded {
+			handle = stored.(*gorm.DB)
+		} else {
+			go func() {
+				<-ctx.Done()
+				db.bound.Delete(ctx)
+			}()
+		}
+	}
+	return &DB{handle, db.conn, db.bound}
+}
+
+// Migrate creates or updates every table used by the storage
+func (db *DB) Migrate() error {
+	// checked first so a build without FTS5 leaves the schema untouched
+	fts, err := db.hasFTS5()
+	if err != nil {
+		return err
+	}
+	if !fts {
+		return ErrNoFTS5
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Question{}, &model.Answer{},
+		&model.Comment{}, &model.Vote{}, &model.Tag{}, &model.Revision{},
+		&model.Session{}, &model.APIToken{}, &model.TwoFactor{},
+		&model.LoginAttempt{}, &model.LoginThrottle{},
+		&questionTag{}).Error; err != nil {
+		return err
+	}
+	// users created before e-mail verification existed keep posting
+	if err := db.Model(&model.User{}).Where("verified IS NULL").
+		UpdateColumn("verified", true).Error; err != nil {
+		return err
+	}
+	return db.Exec(createSearchIndex).Error
+}
+
+// paginate applies the page order and window to scope, one extra row is
+// fetched to know whether a next page exists
+func paginate(scope *gorm.DB, page storage.Page) *gorm.DB {
+	if page.Sort != "" {
+		order := `"` + page.Sort + `"`
+		if page.Desc {
+			order += " DESC"
+		}
+		scope = scope.Order(order)
+	}
+	return scope.Order("id").Limit(page.Limit + 1).Offset(page.Offset)
+}
+
+// dropPosts deletes posts of a kind with their votes, revisions and search
+// entries
+func dropPosts(tx *gorm.DB, kind string, post interface{}, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("id IN (?)", ids).Delete(post).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target = ? AND target_id IN (?)", kind, ids).
+		Delete(&model.Vote{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target = ? AND target_id IN (?)", kind, ids).
+		Delete(&model.Revision{}).Error; err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM search_index WHERE kind = ? AND ref_id IN (?)",
+		kind, ids).Error
 }
 
 func (db *DB) Close() error {
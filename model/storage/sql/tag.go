@@ -0,0 +1,193 @@
+package sql
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// questionTag links questions to their canonical tags
+type questionTag struct {
+	QuestionID int    `gorm:"primary_key;auto_increment:false"`
+	Tag        string `gorm:"primary_key;size:25;index"`
+}
+
+func (questionTag) TableName() string {
+	return "question_tags"
+}
+
+type tagCount struct {
+	Tag   string
+	Count int
+}
+
+func (db *DB) FindAllTag(ctx context.Context) ([]model.Tag, error) {
+	db = db.with(ctx)
+
+	var tags []model.Tag
+	if err := db.Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []tagCount
+	if err := db.Model(&questionTag{}).Select("tag, COUNT(*) AS count").
+		Group("tag").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	count := map[string]int{}
+	for _, c := range counts {
+		count[c.Tag] = c.Count
+	}
+	for i := range tags {
+		tags[i].Count = count[tags[i].Name]
+	}
+
+	return tags, nil
+}
+
+func (db *DB) UpdateTag(ctx context.Context, t model.Tag) (model.Tag, error) {
+	db = db.with(ctx)
+
+	tag, err := db.FindTag(ctx, t.Name)
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	tag.Description = t.Description
+	if err := tag.Valid(); err != nil {
+		return model.Tag{}, err
+	}
+
+	if err := db.Model(&tag).UpdateColumn("description",
+		tag.Description).Error; err != nil {
+		return model.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+func (db *DB) FindTag(ctx context.Context, name string) (model.Tag, error) {
+	db = db.with(ctx)
+
+	var tag model.Tag
+
+	if err := db.Where("name = ?", model.NormalizeTag(name)).
+		First(&tag).Error; err != nil {
+		return model.Tag{}, storage.ErrTagNotFound
+	}
+	if err := db.Model(&questionTag{}).Where("tag = ?", tag.Name).
+		Count(&tag.Count).Error; err != nil {
+		return model.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+func (db *DB) MergeTag(ctx context.Context, from, to string) error {
+	db = db.with(ctx)
+
+	from, to = model.NormalizeTag(from), model.NormalizeTag(to)
+	if _, err := db.FindTag(ctx, from); err != nil {
+		return err
+	}
+	to = db.resolveTags(ctx, []string{to})[0]
+	if from == to {
+		return model.ErrInvalidTag
+	}
+	if err := model.ValidTagName(to); err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if err := registerTags(tx, []string{to}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// questions already tagged with both keep a single link
+	if err := tx.Where("tag = ? AND question_id IN (?)", from,
+		tx.Model(&questionTag{}).Select("question_id").Where("tag = ?", to).
+			SubQuery()).Delete(&questionTag{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&questionTag{}).Where("tag = ?", from).
+		UpdateColumn("tag", to).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.Tag{}).Where("name = ? OR synonym_of = ?", from,
+		from).UpdateColumn("synonym_of", to).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// resolveTags normalizes names replacing synonyms by their canonical tag
+func (db *DB) resolveTags(ctx context.Context, names []string) []string {
+	tags := model.NormalizeTags(names)
+	for i, name := range tags {
+		if tag, err := db.FindTag(ctx, name); err == nil && tag.SynonymOf != "" {
+			tags[i] = tag.SynonymOf
+		}
+	}
+	return model.NormalizeTags(tags)
+}
+
+// registerTags adds unknown tags to the catalogue
+func registerTags(tx *gorm.DB, names []string) error {
+	for _, name := range names {
+		if err := tx.Where(model.Tag{Name: name}).
+			FirstOrCreate(&model.Tag{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveTags replaces the tags linked to a question
+func saveTags(tx *gorm.DB, question int, names []string) error {
+	if err := registerTags(tx, names); err != nil {
+		return err
+	}
+	if err := tx.Where("question_id = ?", question).
+		Delete(&questionTag{}).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := tx.Create(&questionTag{question, name}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags fills the Tags field of every question
+func (db *DB) loadTags(ctx context.Context, questions []model.Question) error {
+	db = db.with(ctx)
+
+	if len(questions) == 0 {
+		return nil
+	}
+	ids := make([]int, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+
+	var links []questionTag
+	if err := db.Where("question_id IN (?)", ids).Order("tag").
+		Find(&links).Error; err != nil {
+		return err
+	}
+	tags := map[int][]string{}
+	for _, link := range links {
+		tags[link.QuestionID] = append(tags[link.QuestionID], link.Tag)
+	}
+	for i := range questions {
+		questions[i].Tags = tags[questions[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) SaveTwoFactor(ctx context.Context,
+	t model.TwoFactor) (model.TwoFactor, error) {
+
+	db = db.with(ctx)
+
+	if _, err := db.FindUser(ctx, t.UserID); err != nil {
+		return model.TwoFactor{}, storage.ErrUserNotFound
+	}
+
+	t.When = time.Now()
+	if err := db.Save(&t).Error; err != nil {
+		return model.TwoFactor{}, err
+	}
+
+	return t, nil
+}
+
+func (db *DB) UpdateTwoFactor(ctx context.Context, old,
+	t model.TwoFactor) error {
+
+	db = db.with(ctx)
+
+	update := db.Model(&model.TwoFactor{}).
+		Where("user_id = ? AND last_step = ? AND recovery = ?", old.UserID,
+			old.LastStep, old.Recovery).
+		Updates(map[string]interface{}{
+			"secret":    t.Secret,
+			"enabled":   t.Enabled,
+			"last_step": t.LastStep,
+			"recovery":  t.Recovery,
+		})
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return storage.ErrTwoFactorNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteTwoFactor(ctx context.Context, user int) error {
+	db = db.with(ctx)
+
+	remove := db.Where("user_id = ?", user).Delete(&model.TwoFactor{})
+	if remove.Error != nil {
+		return remove.Error
+	}
+	if remove.RowsAffected == 0 {
+		return storage.ErrTwoFactorNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) FindTwoFactor(ctx context.Context, user int) (model.TwoFactor,
+	error) {
+
+	db = db.with(ctx)
+
+	var t model.TwoFactor
+
+	if err := db.Where("user_id = ?", user).First(&t).Error; err != nil {
+		return model.TwoFactor{}, storage.ErrTwoFactorNotFound
+	}
+
+	return t, nil
+}
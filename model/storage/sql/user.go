@@ -1,35 +1,56 @@
 package sql
 
 import (
+	"context"
 	"html"
 	"time"
 
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
-func (db *DB) FindAllUser() ([]model.User, error) {
+func (db *DB) FindAllUser(ctx context.Context,
+	query storage.Query) ([]model.User, string, error) {
+
+	db = db.with(ctx)
+
+	page, err := query.Page("since", "nick")
+	if err != nil {
+		return nil, "", err
+	}
+
 	var users []model.User
-	if err := db.Find(&users).Error; err != nil {
-		return nil, err
+	if err := paginate(db.DB, page).Find(&users).Error; err != nil {
+		return nil, "", err
 	}
-	return model.OmitPass(users), nil
+	next := page.Next(len(users))
+	if len(users) > page.Limit {
+		users = users[:page.Limit]
+	}
+
+	return model.OmitPass(users), next, nil
 }
 
-func (db *DB) CreateUser(u model.User) (model.User, error) {
-	if _, err := db.FindUserByNick(u.Nick); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+func (db *DB) CreateUser(ctx context.Context, u model.User) (model.User,
+	error) {
+
+	db = db.with(ctx)
+
+	if _, err := db.FindUserByNick(ctx, u.Nick); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
-	if _, err := db.FindUserByEmail(u.Email); err == nil {
-		return model.User{}, errors.Errorf("Cannot create user")
+	if _, err := db.FindUserByEmail(ctx, u.Email); err == nil {
+		return model.User{}, storage.ErrUserAlreadyExist
 	}
 
 	u.Since = time.Now()
+	u.Role = model.RoleUser
+	u.Verified = false
 	if errs := u.Valid(); errs != nil {
-		return model.User{}, errors.Errorf("Cannot create user: %s", errs)
+		return model.User{}, errors.Wrap(errs, "Cannot create user")
 	}
 	encPass, err := model.GenPass(u.Password)
 	if err != nil {
@@ -46,14 +67,23 @@ func (db *DB) CreateUser(u model.User) (model.User, error) {
 
 }
 
-func (db *DB) UpdateUser(u model.User) (model.User, error) {
+func (db *DB) UpdateUser(ctx context.Context, u model.User) (model.User,
+	error) {
 
-	user, err := db.FindUser(u.ID)
-	if err != nil {
+	db = db.with(ctx)
+
+	// FindUser omits the password hash Save would overwrite
+	var user model.User
+	if err := db.First(&user, u.ID).Error; err != nil {
 		return model.User{}, storage.ErrUserNotFound
 	}
 
+	// the new password is validated before hashing, not the hash
 	if u.Password != "" {
+		u.Email = user.Email
+		if errs := u.ValidPassword(); errs != nil {
+			return model.User{}, errs
+		}
 		newPass, err := model.GenPass(u.Password)
 		if err != nil {
 			return model.User{}, err
@@ -63,7 +93,10 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 	user.Nick = u.Nick
 	user.Avatar = html.EscapeString(u.Avatar)
 
-	if errs := user.Valid(); errs != nil {
+	if errs := user.ValidNick(); errs != nil {
+		return model.User{}, errs
+	}
+	if errs := user.ValidAvatar(); errs != nil {
 		return model.User{}, errs
 	}
 
@@ -72,15 +105,42 @@ func (db *DB) UpdateUser(u model.User) (model.User, error) {
 	}
 	u.Password = ""
 	u.Since = user.Since
+	u.Role = user.Role
+	u.Verified = user.Verified
 
 	return u, nil
 }
 
-func (db *DB) DeleteUser(id int) error {
+func (db *DB) DeleteUser(ctx context.Context, id int) error {
+	db = db.with(ctx)
+
 	return db.Where("id = ?", id).Delete(&model.User{}).Error
 }
 
-func (db *DB) FindUser(id int) (model.User, error) {
+func (db *DB) SetUserRole(ctx context.Context, id int, role string) (model.User,
+	error) {
+
+	db = db.with(ctx)
+
+	if err := model.ValidRole(role); err != nil {
+		return model.User{}, err
+	}
+	user, err := db.FindUser(ctx, id)
+	if err != nil {
+		return model.User{}, storage.ErrUserNotFound
+	}
+
+	if err := db.Model(&user).UpdateColumn("role", role).Error; err != nil {
+		return model.User{}, err
+	}
+	user.Role = role
+
+	return user, nil
+}
+
+func (db *DB) FindUser(ctx context.Context, id int) (model.User, error) {
+	db = db.with(ctx)
+
 	var user model.User
 
 	if err := db.First(&user, id).Error; err != nil {
@@ -90,7 +150,11 @@ func (db *DB) FindUser(id int) (model.User, error) {
 	return user, nil
 }
 
-func (db *DB) FindUserByNick(nick string) (model.User, error) {
+func (db *DB) FindUserByNick(ctx context.Context, nick string) (model.User,
+	error) {
+
+	db = db.with(ctx)
+
 	var user model.User
 
 	if err := db.Where("nick = ?", nick).First(&user).Error; err != nil {
@@ -100,7 +164,11 @@ func (db *DB) FindUserByNick(nick string) (model.User, error) {
 	return user, nil
 }
 
-func (db *DB) FindUserByEmail(email string) (model.User, error) {
+func (db *DB) FindUserByEmail(ctx context.Context, email string) (model.User,
+	error) {
+
+	db = db.with(ctx)
+
 	var user model.User
 
 	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
@@ -110,14 +178,106 @@ func (db *DB) FindUserByEmail(email string) (model.User, error) {
 	return user, nil
 }
 
-func (db *DB) Login(login string, pass string) error {
-	user, err := db.FindUserByEmail(login)
-	if err != nil {
-		argon2.CompareHashAndPassword([]byte("not found"), []byte(pass))
-		return errors.Errorf("user or pass invalid")
+func (db *DB) Login(ctx context.Context, login string, pass string) error {
+	db = db.with(ctx)
+
+	// FindUserByEmail omits the password hash the comparison needs
+	var user model.User
+	if err := db.Where("email = ?", login).First(&user).Error; err != nil {
+		password.CompareDummy([]byte(pass))
+		return storage.ErrInvalidCredentials
+	}
+	if err := password.Compare([]byte(user.Password), []byte(pass)); err != nil {
+		return storage.ErrInvalidCredentials
 	}
-	if err := argon2.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
-		return errors.Errorf("user or pass invalid")
+	if password.NeedsRehash([]byte(user.Password)) {
+		db.rehash(user, pass)
 	}
 	return nil
 }
+
+// rehash upgrades the password hash of user to argon2id with the current policy
+// while the password is at hand, the old hash keeps working when it fails
+func (db *DB) rehash(user model.User, pass string) {
+	newPass, err := model.GenPass(pass)
+	if err != nil {
+		return
+	}
+	// a password changed meanwhile is kept
+	db.Model(&model.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		UpdateColumn("password", newPass)
+}
+
+func (db *DB) PasswordStamp(ctx context.Context, id int) (string, error) {
+	db = db.with(ctx)
+
+	var user model.User
+	if err := db.Where("id = ?", id).First(&user).Error; err != nil {
+		return "", storage.ErrUserNotFound
+	}
+	return model.PasswordStamp(user.Password), nil
+}
+
+func (db *DB) ResetPassword(ctx context.Context, id int, stamp,
+	password string) error {
+
+	db = db.with(ctx)
+
+	var user model.User
+	if err := db.Where("id = ?", id).First(&user).Error; err != nil {
+		return storage.ErrUserNotFound
+	}
+	if model.PasswordStamp(user.Password) != stamp {
+		return storage.ErrPasswordChanged
+	}
+	err := (model.User{Nick: user.Nick, Email: user.Email,
+		Password: password}).ValidPassword()
+	if err != nil {
+		return err
+	}
+	newPass, err := model.GenPass(password)
+	if err != nil {
+		return err
+	}
+
+	// the old hash condition keeps a concurrent reset from being overwritten
+	tx := db.Begin()
+	update := tx.Model(&model.User{}).
+		Where("id = ? AND password = ?", id, user.Password).
+		UpdateColumn("password", newPass)
+	if update.Error != nil {
+		tx.Rollback()
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		tx.Rollback()
+		return storage.ErrPasswordChanged
+	}
+	// whoever knew the old password loses the sessions opened with it
+	if err := tx.Model(&model.Session{}).Where("user_id = ?", id).
+		UpdateColumn("revoked", true).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (db *DB) VerifyUser(ctx context.Context, id int,
+	email string) (model.User, error) {
+
+	db = db.with(ctx)
+
+	update := db.Model(&model.User{}).
+		Where("id = ? AND email = ?", id, email).
+		UpdateColumn("verified", true)
+	if update.Error != nil {
+		return model.User{}, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return model.User{}, storage.ErrUserNotFound
+	}
+
+	return db.FindUser(ctx, id)
+}
@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (db *DB) CastVote(ctx context.Context, v model.Vote) (model.Vote, error) {
+	db = db.with(ctx)
+
+	if err := v.Valid(); err != nil {
+		return model.Vote{}, err
+	}
+	target, err := db.voteTarget(ctx, v.Target, v.TargetID)
+	if err != nil {
+		return model.Vote{}, err
+	}
+
+	tx := db.Begin()
+	var vote model.Vote
+	err = tx.Where("user_id = ? AND target = ? AND target_id = ?", v.UserID,
+		v.Target, v.TargetID).First(&vote).Error
+
+	switch {
+	case gorm.IsRecordNotFoundError(err):
+		v.When = time.Now()
+		err = tx.Create(&v).Error
+	case err != nil:
+	case vote.Direction == v.Direction:
+		v = vote
+		v.Direction = 0
+		err = tx.Delete(&vote).Error
+	default:
+		vote.Direction = v.Direction
+		vote.When = time.Now()
+		v = vote
+		err = tx.Save(&vote).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return model.Vote{}, storage.ErrCannotVote
+	}
+
+	var total struct{ Sum int }
+	if err := tx.Model(&model.Vote{}).Select("COALESCE(SUM(direction), 0) AS sum").
+		Where("target = ? AND target_id = ?", v.Target, v.TargetID).
+		Scan(&total).Error; err != nil {
+		tx.Rollback()
+		return model.Vote{}, storage.ErrCannotVote
+	}
+	if err := tx.Model(target).UpdateColumn("votes", total.Sum).Error; err != nil {
+		tx.Rollback()
+		return model.Vote{}, storage.ErrCannotVote
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return model.Vote{}, storage.ErrCannotVote
+	}
+
+	return v, nil
+}
+
+func (db *DB) FindVote(ctx context.Context, user int, target string,
+	id int) (model.Vote, error) {
+
+	db = db.with(ctx)
+
+	var vote model.Vote
+
+	if err := db.Where("user_id = ? AND target = ? AND target_id = ?", user,
+		target, id).First(&vote).Error; err != nil {
+		return model.Vote{}, storage.ErrVoteNotFound
+	}
+
+	return vote, nil
+}
+
+func (db *DB) FindVoteByUser(ctx context.Context, user int,
+	target string) ([]model.Vote, error) {
+
+	db = db.with(ctx)
+
+	var votes []model.Vote
+
+	if err := db.Where("user_id = ? AND target = ?", user, target).
+		Find(&votes).Error; err != nil {
+		return nil, err
+	}
+
+	return votes, nil
+}
+
+// voteTarget returns the model whose votes column must follow the ballots
+func (db *DB) voteTarget(ctx context.Context, target string,
+	id int) (interface{}, error) {
+
+	switch target {
+	case model.QuestionVote:
+		question, err := db.FindQuestion(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &question, nil
+	case model.AnswerVote:
+		answer, err := db.FindAnswer(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &answer, nil
+	case model.CommentVote:
+		comment, err := db.FindComment(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &comment, nil
+	}
+	return nil, model.ErrInvalidVote
+}
@@ -1,59 +1,199 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"securecodewarrior.com/ddias/heapoverflow/model"
 )
 
+// Storage gathers every storage of the application. All methods take the
+// context of the request they serve, backends talking to a database give up
+// once it is cancelled or past its deadline.
 type Storage interface {
 	UserStorage
 	QuestionStorage
+	AnswerStorage
 	CommentStorage
+	VoteStorage
+	TagStorage
+	SearchStorage
+	RevisionStorage
+	SessionStorage
+	APITokenStorage
+	TwoFactorStorage
+	LoginAttemptStorage
 }
 
 var (
 	ErrUserNotFound         = errors.New("User not found")
+	ErrUserAlreadyExist     = errors.New("Cannot create user")
+	ErrInvalidCredentials   = errors.New("user or pass invalid")
 	ErrQuestionNotFound     = errors.New("Question not found")
+	ErrAnswerNotFound       = errors.New("Answer not found")
 	ErrCommentNotFound      = errors.New("Comment not found")
 	ErrQuestionAlreadyExist = errors.New("Question already exist")
 	ErrCannotVote           = errors.New("Cannot change votes")
+	ErrVoteNotFound         = errors.New("Vote not found")
+	ErrTagNotFound          = errors.New("Tag not found")
+	ErrRevisionNotFound     = errors.New("Revision not found")
+	ErrSessionNotFound      = errors.New("Session not found")
+	ErrAPITokenNotFound     = errors.New("API token not found")
+	ErrTwoFactorNotFound    = errors.New("Two-factor authentication not found")
+	ErrPasswordChanged      = errors.New("Password changed")
 )
 
 type UserStorage interface {
-	FindAllUser() ([]model.User, error)
-	CreateUser(model.User) (model.User, error)
-	UpdateUser(model.User) (model.User, error)
-	DeleteUser(int) error
+	FindAllUser(context.Context, Query) ([]model.User, string, error)
+	CreateUser(context.Context, model.User) (model.User, error)
+	UpdateUser(context.Context, model.User) (model.User, error)
+	DeleteUser(context.Context, int) error
+	// SetUserRole is the only way to change the role of a user, new users
+	// always get model.RoleUser
+	SetUserRole(context.Context, int, string) (model.User, error)
 
-	FindUser(int) (model.User, error)
+	FindUser(context.Context, int) (model.User, error)
 
-	FindUserByNick(string) (model.User, error)
-	FindUserByEmail(string) (model.User, error)
-	Login(string, string) error
+	FindUserByNick(context.Context, string) (model.User, error)
+	FindUserByEmail(context.Context, string) (model.User, error)
+	Login(context.Context, string, string) error
+
+	// PasswordStamp returns the model.PasswordStamp of the password of a
+	// user, ResetPassword sets a new password only while the stamp is the
+	// given one and fails with ErrPasswordChanged otherwise, a reset revokes
+	// every session of the user
+	PasswordStamp(context.Context, int) (string, error)
+	ResetPassword(context.Context, int, string, string) error
+	// VerifyUser marks a user as verified while its email is the given one
+	VerifyUser(context.Context, int, string) (model.User, error)
 }
 
 type QuestionStorage interface {
-	FindAllQuestion() ([]model.Question, error)
-	CreateQuestion(model.Question) (model.Question, error)
-	UpdateQuestion(model.Question) (model.Question, error)
+	FindAllQuestion(context.Context, Query) ([]model.Question, string, error)
+	CreateQuestion(context.Context, model.Question) (model.Question, error)
+	UpdateQuestion(context.Context, model.Question) (model.Question, error)
+	// DeleteQuestion also removes the answers, comments and votes of the
+	// question, revisions are kept as the audit trail
+	DeleteQuestion(context.Context, int) error
+
+	FindQuestion(context.Context, int) (model.Question, error)
+
+	FindQuestionByTitle(context.Context, string) (model.Question, error)
+	FindQuestionByAuthor(context.Context, int) ([]model.Question, error)
+}
 
-	FindQuestion(int) (model.Question, error)
+type AnswerStorage interface {
+	CreateAnswer(context.Context, model.Answer) (model.Answer, error)
+	UpdateAnswer(context.Context, model.Answer) (model.Answer, error)
+	// DeleteAnswer also removes the comments and votes of the answer
+	DeleteAnswer(context.Context, int) error
 
-	FindQuestionByTitle(string) (model.Question, error)
-	FindQuestionByAuthor(int) ([]model.Question, error)
-	UpQuestion(int) error
-	DownQuestion(int) error
+	FindAnswer(context.Context, int) (model.Answer, error)
+
+	FindAnswerByAuthor(context.Context, int) ([]model.Answer, error)
+	FindAnswerByQuestion(context.Context, int) ([]model.Answer, error)
+	// AcceptAnswer marks the answer as the accepted one of the question,
+	// answer 0 clears the accepted answer
+	AcceptAnswer(context.Context, int, int) error
 }
 
 type CommentStorage interface {
-	CreateComment(model.Comment) (model.Comment, error)
-	UpdateComment(model.Comment) (model.Comment, error)
+	CreateComment(context.Context, model.Comment) (model.Comment, error)
+	UpdateComment(context.Context, model.Comment) (model.Comment, error)
+	DeleteComment(context.Context, int) error
+
+	FindComment(context.Context, int) (model.Comment, error)
+
+	FindCommentByAuthor(context.Context, int) ([]model.Comment, error)
+	FindCommentByQuestion(context.Context, int, Query) ([]model.Comment, string, error)
+	FindCommentByAnswer(context.Context, int) ([]model.Comment, error)
+}
+
+// VoteStorage keeps one ballot per user and target. CastVote toggles: the same
+// direction twice undoes the vote (returned with Direction 0) and the opposite
+// direction switches it. Target Votes counters are recomputed from the ballots.
+type VoteStorage interface {
+	CastVote(context.Context, model.Vote) (model.Vote, error)
+
+	FindVote(context.Context, int, string, int) (model.Vote, error)
+	FindVoteByUser(context.Context, int, string) ([]model.Vote, error)
+}
+
+// TagStorage is the tag catalogue. Tags are created on first use by questions,
+// question tags are always stored with their canonical name and MergeTag turns
+// the first tag into a synonym of the second retagging its questions.
+type TagStorage interface {
+	FindAllTag(context.Context) ([]model.Tag, error)
+	UpdateTag(context.Context, model.Tag) (model.Tag, error)
+
+	FindTag(context.Context, string) (model.Tag, error)
+
+	MergeTag(context.Context, string, string) error
+}
+
+// SearchStorage ranks questions, answers and comments matching every term of
+// the search, only the Limit and Cursor of the Query apply
+type SearchStorage interface {
+	Search(context.Context, string, Query) ([]model.SearchResult, string, error)
+}
+
+// RevisionStorage keeps the edit history of questions, answers and comments.
+// CreateRevision numbers the revision after the last one of its target and
+// revisions are found by target, target id and number.
+type RevisionStorage interface {
+	CreateRevision(context.Context, model.Revision) (model.Revision, error)
+
+	FindRevision(context.Context, string, int, int) (model.Revision, error)
+	FindRevisionByTarget(context.Context, string, int) ([]model.Revision, error)
+}
+
+// SessionStorage keeps the login sessions by id. RotateSession swaps the
+// refresh token hash of an active session only while the second argument is
+// the current hash, so each refresh token is accepted once and a concurrent
+// reuse gets ErrSessionNotFound. The replaced hash is kept in Rotated.
+type SessionStorage interface {
+	CreateSession(context.Context, model.Session) (model.Session, error)
+	RotateSession(context.Context, string, string, string) (model.Session, error)
+	RevokeSession(context.Context, string) error
+
+	FindSession(context.Context, string) (model.Session, error)
+}
+
+// APITokenStorage keeps the personal access tokens of users, tokens are found
+// by the hash of their secret and TouchAPIToken records their last use
+type APITokenStorage interface {
+	CreateAPIToken(context.Context, model.APIToken) (model.APIToken, error)
+	TouchAPIToken(context.Context, int, time.Time) error
+	DeleteAPIToken(context.Context, int) error
+
+	FindAPIToken(context.Context, int) (model.APIToken, error)
+	FindAPITokenByHash(context.Context, string) (model.APIToken, error)
+	FindAPITokenByUser(context.Context, int) ([]model.APIToken, error)
+}
+
+// TwoFactorStorage keeps the TOTP enrollment of users by user id. SaveTwoFactor
+// creates or replaces it and UpdateTwoFactor replaces it only while its
+// LastStep and Recovery are those of the first argument, so a code or a
+// recovery code is accepted once and a concurrent use gets
+// ErrTwoFactorNotFound.
+type TwoFactorStorage interface {
+	SaveTwoFactor(context.Context, model.TwoFactor) (model.TwoFactor, error)
+	UpdateTwoFactor(context.Context, model.TwoFactor, model.TwoFactor) error
+	DeleteTwoFactor(context.Context, int) error
+
+	FindTwoFactor(context.Context, int) (model.TwoFactor, error)
+}
 
-	FindComment(int) (model.Comment, error)
+// LoginAttemptStorage keeps the audit trail of failed logins, found by email,
+// and the throttles of emails and IP addresses. FailLogin counts a failure
+// atomically, starting over when the last one is older than the duration, and
+// FindLoginThrottle returns a throttle without failures for unknown keys.
+type LoginAttemptStorage interface {
+	CreateLoginAttempt(context.Context, model.LoginAttempt) (model.LoginAttempt, error)
+	FailLogin(context.Context, string, time.Duration) (model.LoginThrottle, error)
+	ClearLoginThrottle(context.Context, string) error
 
-	FindCommentByAuthor(int) ([]model.Comment, error)
-	FindCommentByQuestion(int) ([]model.Comment, error)
-	UpComment(int) error
-	DownComment(int) error
+	FindLoginAttempts(context.Context, string, Query) ([]model.LoginAttempt, string, error)
+	FindLoginThrottle(context.Context, string) (model.LoginThrottle, error)
 }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTagMaxSize         = 25
+	defaultTagsPerQuestion    = 5
+	defaultTagDescriptionSize = 500
+)
+
+var ErrInvalidTag = errors.New("Invalid Tag structure")
+
+// Tag is an entry of the tag catalogue, Count is computed on read and
+// SynonymOf names the canonical tag this one was merged into
+type Tag struct {
+	Name        string `json:"name" bson:"_id" gorm:"primary_key;size:25"`
+	Description string `json:"description,omitempty" gorm:"size:500"`
+	Count       int    `json:"count" gorm:"-" bson:"-"`
+	SynonymOf   string `json:"synonym_of,omitempty" bson:"synonym_of,omitempty" gorm:"index;size:25"`
+}
+
+// NormalizeTag returns the canonical spelling of a tag name
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTags normalizes every name dropping duplicates and empty ones
+func NormalizeTags(names []string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		name = NormalizeTag(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+func ValidTagName(name string) error {
+	return validTagName(ErrInvalidTag, "name", name)
+}
+
+// validTagName checks a tag name held by field of a structure of kind
+func validTagName(kind error, field, name string) error {
+	reTag := regexp.MustCompile(`^[a-z0-9][a-z0-9+#.\-]*$`)
+	max := policy.Tag.NameMaxSize
+	if len(name) > max || !reTag.MatchString(name) {
+		return invalid(kind, field, "format",
+			params{"tag": name, "max": max},
+			"Invalid tag %q: up to %d lowercase letters, digits or +#.-",
+			name, max)
+	}
+	return nil
+}
+
+func (t Tag) Valid() error {
+	return validate(ErrInvalidTag,
+		func() error {
+			return validTagName(ErrInvalidTag, "name", t.Name)
+		},
+		func() error {
+			max := policy.Tag.DescriptionMaxSize
+			if len(t.Description) > max {
+				return invalid(ErrInvalidTag, "description", "length",
+					params{"max": max},
+					"Invalid tag description length must be below %d characters",
+					max)
+			}
+			return nil
+		},
+		func() error {
+			if t.SynonymOf == "" {
+				return nil
+			}
+			return validTagName(ErrInvalidTag, "synonym_of", t.SynonymOf)
+		},
+	)
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// TwoFactor is the TOTP enrollment of a user, Enabled once a first code
+// confirmed it. LastStep is the time step of the last code accepted so codes
+// are used once and Recovery holds the argon2 hashes of the unused recovery
+// codes separated by spaces.
+type TwoFactor struct {
+	UserID   int       `json:"user" bson:"_id" gorm:"primary_key;auto_increment:false"`
+	Secret   string    `json:"-" bson:"secret" gorm:"size:64"`
+	Enabled  bool      `json:"enabled"`
+	LastStep int64     `json:"-" bson:"last_step"`
+	Recovery string    `json:"-" bson:"recovery" gorm:"size:2000"`
+	When     time.Time `json:"when,omitempty"`
+}
+
+// RecoveryHashes returns the hashes of the unused recovery codes
+func (t TwoFactor) RecoveryHashes() []string {
+	return strings.Fields(t.Recovery)
+}
+
+// RecoveryLeft counts the unused recovery codes
+func (t TwoFactor) RecoveryLeft() int {
+	return len(t.RecoveryHashes())
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"image"
 	"regexp"
 	"strings"
@@ -9,15 +11,18 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/vincent-petithory/dataurl"
-	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/password"
 )
 
 const (
 	defaultNickMaxSize     = 16
 	defaultAvatarMaxSize   = 4096
 	defaultAvatarDim       = 40
-	defaultMinPasswordSize = 7
+	defaultMinPasswordSize = 10
 	defaultMaxPasswordSize = 128
+	defaultPasswordClasses = 3
+	defaultPasswordRepeat  = 2
+	defaultPasswordScore   = 2
 )
 
 type User struct {
@@ -27,15 +32,25 @@ type User struct {
 	Nick     string    `json:"nick,omitempty" gorm:"unique_index;size:16"`
 	Avatar   string    `json:"avatar,omitempty" bson:"avatar,omitempty"`
 	Password string    `json:"password,omitempty" gorm:"not null"`
+	Role     string    `json:"role,omitempty" gorm:"size:16"`
+	Verified bool      `json:"verified"`
 }
 
-func GenPass(password string) (string, error) {
-	// pass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	pass, err := argon2.GenerateFromPassword([]byte(password), nil, nil)
+// GenPass hashes pass with argon2id, hashes of the other schemes of the
+// password package are only verified
+func GenPass(pass string) (string, error) {
+	hash, err := password.Hash([]byte(pass))
 	if err != nil {
 		return "", errors.Wrap(err, "cannot generate hash")
 	}
-	return string(pass), nil
+	return hash, nil
+}
+
+// PasswordStamp identifies a password hash without revealing it, the stamp
+// changes along with the password
+func PasswordStamp(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:16])
 }
 
 // need to use copy because slice receiver/param can have elements changed even
@@ -49,32 +64,37 @@ func OmitPass(users []User) []User {
 	return usersPassOmit
 }
 
+// errEmail reports a malformed e-mail address
+func errEmail() error {
+	return invalid(ErrInvalidEmail, "email", "format", nil, "Invalid e-mail")
+}
+
 func (u User) validEmail() error {
 	digits := regexp.MustCompile(`^\d+$`)
 	emailRE := regexp.MustCompile(`^[\w` +
 		regexp.QuoteMeta("!#$%&‘*+–/=?^_`.{|}~") + `]+@[\w\.]+$`)
 	if emailRE.MatchString(u.Email) == false {
-		return ErrInvalidEmail
+		return errEmail()
 	}
 	email := strings.Split(u.Email, "@")
 	if len(email) != 2 {
-		return ErrInvalidEmail
+		return errEmail()
 	}
 	local, domain := email[0], email[1]
 	if len(local) > 64 || len(domain) > 255 {
-		return ErrInvalidEmail
+		return errEmail()
 	}
 
 	for _, dnsLabel := range strings.Split(domain, ".") {
 		if len(dnsLabel) > 63 || digits.MatchString(dnsLabel) ||
 			dnsLabel[0] == '-' || dnsLabel[len(dnsLabel)-1] == '-' {
-			return ErrInvalidEmail
+			return errEmail()
 		}
 	}
 
 	if local[0] == '.' || local[len(local)-1] == '.' ||
 		strings.Contains(local, "..") {
-		return ErrInvalidEmail
+		return errEmail()
 	}
 
 	return nil
@@ -82,8 +102,10 @@ func (u User) validEmail() error {
 
 func (u User) ValidNick() error {
 	reNick := regexp.MustCompile(`^\w+$`)
-	if len(u.Nick) > defaultNickMaxSize || !reNick.MatchString(u.Nick) {
-		return errors.Errorf("Invalid nick format, only letters, digits and hyphens")
+	max := policy.User.NickMaxSize
+	if len(u.Nick) > max || !reNick.MatchString(u.Nick) {
+		return invalid(ErrInvalidUser, "nick", "format", params{"max": max},
+			"Invalid nick format, only letters, digits and hyphens")
 	}
 	return nil
 }
@@ -94,24 +116,29 @@ func (u User) ValidAvatar() error {
 	}
 	avatar, err := dataurl.DecodeString(u.Avatar)
 	if err != nil {
-		return errors.Wrap(err, "cannot decode avatar")
+		return invalid(ErrInvalidUser, "avatar", "format", nil,
+			"cannot decode avatar: %s", err)
 	}
-	if len(avatar.Data) > defaultAvatarMaxSize {
-		return errors.Errorf("Max avatar size: %d", defaultAvatarMaxSize)
+	if max := policy.User.AvatarMaxSize; len(avatar.Data) > max {
+		return invalid(ErrInvalidUser, "avatar", "size", params{"max": max},
+			"Max avatar size: %d", max)
 	}
 	avatarBuffer := bytes.NewBuffer(avatar.Data)
 	avatarImg, _, err := image.Decode(avatarBuffer)
 	if err != nil {
-		return errors.Errorf("Cannot decode avatar image")
+		return invalid(ErrInvalidUser, "avatar", "format", nil,
+			"Cannot decode avatar image")
 	}
-	if avatarImg.Bounds().Max.X > defaultAvatarDim ||
-		avatarImg.Bounds().Max.Y > defaultAvatarDim {
-		return errors.Errorf("Avatar exceeds %d dimensions", defaultAvatarDim)
+	dim := policy.User.AvatarDim
+	if avatarImg.Bounds().Max.X > dim || avatarImg.Bounds().Max.Y > dim {
+		return invalid(ErrInvalidUser, "avatar", "dimensions",
+			params{"max": dim}, "Avatar exceeds %d dimensions", dim)
 	}
 	return nil
 }
 
 func (u User) ValidPassword() error {
+	rule := policy.Password
 
 	rules := []passRules{
 		{oneDigit, "at least 1 digit (0-9)"},
@@ -128,40 +155,56 @@ func (u User) ValidPassword() error {
 		}
 	}
 
-	if len(rulesFailing) > 1 {
-		return errors.Errorf("Invalid password %s", strings.Join(rulesFailing, "\n"))
+	if len(rules)-len(rulesFailing) < rule.MinClasses {
+		return invalid(ErrInvalidUser, "password", "charset",
+			params{"missing": rulesFailing, "min": rule.MinClasses},
+			"Invalid password %s", strings.Join(rulesFailing, "\n"))
 	}
 
-	if len(u.Password) < 10 || len(u.Password) > 128 {
-		return errors.Errorf("Invalid password: length must be between %d and %d characters",
-			defaultMinPasswordSize, defaultMaxPasswordSize)
+	if len(u.Password) < rule.MinSize || len(u.Password) > rule.MaxSize {
+		return invalid(ErrInvalidUser, "password", "length",
+			params{"min": rule.MinSize, "max": rule.MaxSize},
+			"Invalid password: length must be between %d and %d characters",
+			rule.MinSize, rule.MaxSize)
 	}
 
-	if seqOf(u.Password) {
-		return errors.Errorf("Invalid password: not more than 2 identical characters in a row (e.g., 111 not allowed)")
+	if rule.MaxRepeat > 0 && seqOf(u.Password, rule.MaxRepeat) {
+		return invalid(ErrInvalidUser, "password", "repeat",
+			params{"max": rule.MaxRepeat},
+			"Invalid password: not more than %d identical characters in a row",
+			rule.MaxRepeat)
 	}
 
-	return nil
-}
-
-func (u User) Valid() error {
-	validation := [](func() error){
-		u.validEmail,
-		u.ValidAvatar,
-		u.ValidNick,
-		u.ValidPassword,
+	// the nick and e-mail are the first words an attacker tries
+	estimate := password.Strength(u.Password, u.Nick, u.Email)
+	if estimate.Score < rule.MinScore {
+		reasons := make([]string, len(estimate.Feedback))
+		for i, code := range estimate.Feedback {
+			reasons[i] = password.Messages[code]
+		}
+		return invalid(ErrInvalidUser, "password", "strength",
+			params{"score": estimate.Score, "min": rule.MinScore,
+				"feedback": estimate.Feedback},
+			"Invalid password: too easy to guess, %s",
+			strings.Join(reasons, ", "))
 	}
 
-	var errFound []string
-	for _, fn := range validation {
-		err := fn()
+	if rule.Breaches != nil {
+		count, err := rule.Breaches.Count(u.Password)
 		if err != nil {
-			errFound = append(errFound, err.Error())
+			return errors.Wrap(err, "cannot check password breaches")
+		}
+		if count > rule.MaxBreaches {
+			return invalid(ErrInvalidUser, "password", "breached",
+				params{"count": count, "max": rule.MaxBreaches},
+				"Invalid password: found %d times in data breaches", count)
 		}
-	}
-	if errFound == nil {
-		return nil
 	}
 
-	return errors.Errorf("Invalid user model: %s", strings.Join(errFound, "\n"))
+	return nil
+}
+
+func (u User) Valid() error {
+	return validate(ErrInvalidUser, u.validEmail, u.ValidAvatar, u.ValidNick,
+		u.ValidPassword)
 }
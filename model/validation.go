@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a failed validation rule of one field, Params holds the
+// limits of the rule such as min and max lengths
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Rule    string                 `json:"rule"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationError lists every failed rule of a structure, its cause is the
+// sentinel of the structure such as ErrInvalidQuestion
+type ValidationError struct {
+	kind   error
+	Fields []FieldError `json:"fields"`
+}
+
+type params = map[string]interface{}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return e.kind.Error() + ": " + strings.Join(messages, "\n")
+}
+
+func (e *ValidationError) Cause() error {
+	return e.kind
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.kind
+}
+
+// invalid returns the validation error of a single field rule
+func invalid(kind error, field, rule string, p params, format string,
+	args ...interface{}) error {
+
+	return &ValidationError{kind, []FieldError{{
+		Field:   field,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		Params:  p,
+	}}}
+}
+
+// validate runs every validator of a structure and gathers their failures
+// into one error of kind, any other error such as a failed lookup is returned
+// as is
+func validate(kind error, validators ...func() error) error {
+	found := &ValidationError{kind: kind}
+	for _, fn := range validators {
+		err := fn()
+		if err == nil {
+			continue
+		}
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			return err
+		}
+		found.Fields = append(found.Fields, verr.Fields...)
+	}
+	if len(found.Fields) == 0 {
+		return nil
+	}
+	return found
+}
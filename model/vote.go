@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	VoteUp   = 1
+	VoteDown = -1
+
+	QuestionVote = "question"
+	AnswerVote   = "answer"
+	CommentVote  = "comment"
+)
+
+var ErrInvalidVote = errors.New("Invalid Vote structure")
+
+// Vote is a single user ballot on a question, answer or comment, Votes
+// counters on those models are derived from the sum of Direction
+type Vote struct {
+	ID        int       `json:"id" bson:"_id"`
+	UserID    int       `json:"user" bson:"user_id" gorm:"unique_index:idx_vote_ballot"`
+	Target    string    `json:"target" gorm:"unique_index:idx_vote_ballot;size:16"`
+	TargetID  int       `json:"target_id" bson:"target_id" gorm:"unique_index:idx_vote_ballot"`
+	Direction int       `json:"direction"`
+	When      time.Time `json:"when,omitempty"`
+}
+
+func (v Vote) Valid() error {
+	if v.Direction != VoteUp && v.Direction != VoteDown {
+		return ErrInvalidVote
+	}
+	if v.Target != QuestionVote && v.Target != AnswerVote &&
+		v.Target != CommentVote {
+		return ErrInvalidVote
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
+)
+
+// RetrievePolicy publishes the validation limits so that clients check their
+// forms as the server does
+func (app *app) RetrievePolicy(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return model.CurrentPolicy(), nil
+}
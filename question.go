@@ -4,14 +4,29 @@ import (
 	"net/http"
 
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/jwt"
 	"securecodewarrior.com/ddias/heapoverflow/model"
 )
 
 func (app *app) RetrieveQuestions(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
-	return app.FindAllQuestion()
+	query, err := queryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	questions, next, err := app.FindAllQuestion(r.Context(), query)
+	if err != nil {
+		return nil, err
+	}
+	voted, err := app.ballots(r, model.QuestionVote)
+	if err != nil {
+		return nil, err
+	}
+	for i := range questions {
+		questions[i].Voted = voted[questions[i].ID]
+	}
+
+	return paged{questions, next}, nil
 }
 
 func (app *app) RetrieveQuestion(w http.ResponseWriter,
@@ -22,7 +37,16 @@ func (app *app) RetrieveQuestion(w http.ResponseWriter,
 		return nil, err
 	}
 
-	return app.FindQuestion(id)
+	question, err := app.FindQuestion(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	question.Voted, err = app.ballot(r, model.QuestionVote, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return question, nil
 }
 
 func (app *app) CreateQuestion(w http.ResponseWriter,
@@ -33,21 +57,33 @@ func (app *app) CreateQuestion(w http.ResponseWriter,
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	user, err := app.Storage.FindUserByEmail(payload.Email)
+	user, err := verifiedUser(r)
 	if err != nil {
 		return nil, err
 	}
 	question.UserID = user.ID
 
-	return app.Storage.CreateQuestion(question)
+	question, err = app.Storage.CreateQuestion(r.Context(), question)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), model.Revision{},
+		questionRevision(question, user.ID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return question, nil
 }
 
 func (app *app) UpdateQuestion(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
-	var question model.Question
-	err := jsonFromRequest(&question, r)
+	var edit struct {
+		model.Question
+		Summary string `json:"summary"`
+	}
+	err := jsonFromRequest(&edit, r)
 	if err != nil {
 		return nil, err
 	}
@@ -56,66 +92,101 @@ func (app *app) UpdateQuestion(w http.ResponseWriter,
 		return nil, err
 	}
 
-	payload := jwt.DecodePayload(r)
-	qstore, err := app.Storage.FindQuestion(id)
+	qstore, err := app.Storage.FindQuestion(r.Context(), id)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(qstore.UserID)
+	if err := app.ownerOr(r, qstore.UserID, model.PermEditPost); err != nil {
+		return nil, errors.Wrap(err, "Cannot update another user question")
+	}
+	editor, err := requestUser(r)
 	if err != nil {
 		return nil, err
 	}
-	if payload.Email != author.Email {
-		return nil, errors.Errorf("Cannot update another user question")
+
+	edit.ID = id
+	revised := questionRevision(edit.Question, editor.ID, edit.Summary)
+	if err := revised.Valid(); err != nil {
+		return nil, err
 	}
 
-	question.ID = id
+	question, err := app.Storage.UpdateQuestion(r.Context(), edit.Question)
+	if err != nil {
+		return nil, err
+	}
+	err = app.revise(r.Context(), questionRevision(qstore, qstore.UserID, ""),
+		questionRevision(question, editor.ID, edit.Summary))
+	if err != nil {
+		return nil, err
+	}
 
-	return app.Storage.UpdateQuestion(question)
+	return question, nil
 }
 
-func (app *app) UpVoteQuestion(w http.ResponseWriter,
+func (app *app) DeleteQuestion(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
 	id, err := idFromRequest("id", r)
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	question, err := app.Storage.FindQuestion(id)
+	question, err := app.Storage.FindQuestion(r.Context(), id)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(question.UserID)
-	if err != nil {
-		return nil, err
-	}
-	if payload.Email == author.Email {
-		return nil, errors.Errorf("Cannot up vote yourself")
+	if err := app.ownerOr(r, question.UserID, model.PermDeletePost); err != nil {
+		return nil, errors.Wrap(err, "Cannot delete another user question")
 	}
 
-	return nil, app.Storage.UpQuestion(id)
+	return nil, app.Storage.DeleteQuestion(r.Context(), id)
+}
+
+func (app *app) UpVoteQuestion(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return app.voteQuestion(r, model.VoteUp)
 }
 
 func (app *app) DownVoteQuestion(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
+	return app.voteQuestion(r, model.VoteDown)
+}
+
+func (app *app) voteQuestion(r *http.Request, direction int) (interface{},
+	error) {
+
 	id, err := idFromRequest("id", r)
 	if err != nil {
 		return nil, err
 	}
-	payload := jwt.DecodePayload(r)
-	question, err := app.Storage.FindQuestion(id)
+	voter, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+	question, err := app.Storage.FindQuestion(r.Context(), id)
 	if err != nil {
 		return nil, err
 	}
-	author, err := app.Storage.FindUser(question.UserID)
+	if voter.ID == question.UserID {
+		return nil, errSelfVote
+	}
+
+	vote, err := app.Storage.CastVote(r.Context(), model.Vote{
+		UserID:    voter.ID,
+		Target:    model.QuestionVote,
+		TargetID:  id,
+		Direction: direction,
+	})
 	if err != nil {
 		return nil, err
 	}
-	if payload.Email == author.Email {
-		return nil, errors.Errorf("Cannot up vote yourself")
+
+	question, err = app.Storage.FindQuestion(r.Context(), id)
+	if err != nil {
+		return nil, err
 	}
+	question.Voted = vote.Direction
 
-	return nil, app.Storage.DownQuestion(id)
+	return question, nil
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/diff"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+func (app *app) RetrieveQuestionRevisions(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := app.Storage.FindQuestion(r.Context(), id); err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindRevisionByTarget(r.Context(), model.QuestionVote, id)
+}
+
+func (app *app) RetrieveQuestionRevision(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := idFromRequest("rev", r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindRevision(r.Context(), model.QuestionVote, id, rev)
+}
+
+func (app *app) DiffQuestionRevisions(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	from, err := idFromRequest("from", r)
+	if err != nil {
+		return nil, err
+	}
+	to, err := idFromRequest("to", r)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := app.Storage.FindRevision(r.Context(), model.QuestionVote, id, from)
+	if err != nil {
+		return nil, err
+	}
+	new, err := app.Storage.FindRevision(r.Context(), model.QuestionVote, id, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		From    model.Revision `json:"from"`
+		To      model.Revision `json:"to"`
+		Title   []diff.Line    `json:"title"`
+		Content []diff.Line    `json:"content"`
+	}{old, new, diff.Lines(old.Title, new.Title),
+		diff.Lines(old.Content, new.Content)}, nil
+}
+
+func (app *app) RollbackQuestion(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := idFromRequest("rev", r)
+	if err != nil {
+		return nil, err
+	}
+
+	qstore, err := app.Storage.FindQuestion(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.ownerOr(r, qstore.UserID, model.PermEditPost); err != nil {
+		return nil, errors.Wrap(err, "Cannot roll back another user question")
+	}
+	editor, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := app.Storage.FindRevision(r.Context(), model.QuestionVote, id,
+		rev)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := "Rollback to revision " + strconv.Itoa(rev)
+	edit := model.Question{
+		ID:      id,
+		Title:   revision.Title,
+		Content: revision.Content,
+	}
+	revised := questionRevision(edit, editor.ID, summary)
+	if err := revised.Valid(); err != nil {
+		return nil, err
+	}
+
+	question, err := app.Storage.UpdateQuestion(r.Context(), edit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.revise(r.Context(), questionRevision(qstore, qstore.UserID, ""),
+		questionRevision(question, editor.ID, summary))
+	if err != nil {
+		return nil, err
+	}
+
+	return question, nil
+}
+
+func (app *app) RetrieveAnswerRevisions(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	answer, err := app.answerFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindRevisionByTarget(r.Context(), model.AnswerVote,
+		answer.ID)
+}
+
+func (app *app) RetrieveCommentRevisions(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+	cid, err := idFromRequest("cid", r)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := app.Storage.FindComment(r.Context(), cid)
+	if err != nil {
+		return nil, err
+	}
+	if comment.QuestionID != id {
+		return nil, errors.Wrap(storage.ErrCommentNotFound,
+			"Comment does not belong to question")
+	}
+
+	return app.Storage.FindRevisionByTarget(r.Context(), model.CommentVote, cid)
+}
+
+// revise records the revised version of a target, the stored version is
+// recorded first when the target predates its history. Edits check the
+// revised version before they are saved so an invalid summary changes nothing.
+func (app *app) revise(ctx context.Context, stored,
+	revised model.Revision) error {
+
+	history, err := app.Storage.FindRevisionByTarget(ctx, revised.Target,
+		revised.TargetID)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 && stored.Target != "" {
+		if _, err := app.Storage.CreateRevision(ctx, stored); err != nil {
+			return err
+		}
+	}
+
+	_, err = app.Storage.CreateRevision(ctx, revised)
+	return err
+}
+
+func questionRevision(q model.Question, user int,
+	summary string) model.Revision {
+
+	return model.Revision{
+		Target:   model.QuestionVote,
+		TargetID: q.ID,
+		UserID:   user,
+		Title:    q.Title,
+		Content:  q.Content,
+		Summary:  summary,
+		When:     q.LastEdit,
+	}
+}
+
+func answerRevision(a model.Answer, user int, summary string) model.Revision {
+	return model.Revision{
+		Target:   model.AnswerVote,
+		TargetID: a.ID,
+		UserID:   user,
+		Content:  a.Content,
+		Summary:  summary,
+		When:     a.LastEdit,
+	}
+}
+
+func commentRevision(c model.Comment, user int,
+	summary string) model.Revision {
+
+	return model.Revision{
+		Target:   model.CommentVote,
+		TargetID: c.ID,
+		UserID:   user,
+		Content:  c.Content,
+		Summary:  summary,
+		When:     c.LastEdit,
+	}
+}
@@ -2,55 +2,150 @@ package main
 
 import (
 	"net/http"
+	"strings"
+
+	"securecodewarrior.com/ddias/heapoverflow/model"
 )
 
+// route perm is the permission the request role must grant, empty for
+// routes open to every authenticated user
 type route struct {
 	pattern string
 	method  string
 	handler appHandler
 	public  bool
+	perm    model.Permission
 }
 
 var routes = []route{
-	{"/login", "POST", webapp.Login, true},
-
-	{"/user", "POST", webapp.CreateUser, true},
-	{"/user", "GET", webapp.RetrieveUsers, false},
-	{"/user/{id:[0-9]+}", "GET", webapp.RetrieveUser, false},
-	{"/user/{email}", "GET", webapp.RetrieveUserByEmail, false},
-	{"/user/{id:[0-9]+}", "DELETE", webapp.DeleteUser, false},
-	{"/user/{id:[0-9]+}", "PUT", webapp.UpdateUser, false},
-
-	{"/question", "POST", webapp.CreateQuestion, false},
-	{"/question", "GET", webapp.RetrieveQuestions, false},
-	{"/question/{id:[0-9]+}", "GET", webapp.RetrieveQuestion, false},
-	{"/question/{id:[0-9]+}", "PUT", webapp.UpdateQuestion, false},
-	{"/question/{id:[0-9]+}/vote", "PUT", webapp.UpVoteQuestion, false},
-	{"/question/{id:[0-9]+}/vote", "DELETE", webapp.DownVoteQuestion, false},
+	{"/login", "POST", webapp.Login, true, ""},
+	{"/token/refresh", "POST", webapp.RefreshToken, true, ""},
+	{"/login/2fa", "POST", webapp.LoginTwoFactor, true, ""},
+	{"/login/attempts", "GET", webapp.RetrieveLoginAttempts, false,
+		model.PermManageUsers},
+	{"/login/unlock", "POST", webapp.UnlockLogin, false,
+		model.PermManageUsers},
+	{"/logout", "POST", webapp.Logout, false, ""},
+	{"/password/forgot", "POST", webapp.ForgotPassword, true, ""},
+	{"/password/reset", "POST", webapp.ResetPassword, true, ""},
+	{"/email/verify", "GET", webapp.VerifyEmail, true, ""},
+	{"/email/verify", "POST", webapp.SendVerification, false, ""},
+	{"/.well-known/jwks.json", "GET", webapp.JWKS, true, ""},
+	{"/policy", "GET", webapp.RetrievePolicy, true, ""},
+
+	{"/tokens", "POST", webapp.CreateAPIToken, false, ""},
+	{"/tokens", "GET", webapp.RetrieveAPITokens, false, ""},
+	{"/tokens/{id:[0-9]+}", "DELETE", webapp.DeleteAPIToken, false, ""},
+
+	{"/2fa", "GET", webapp.RetrieveTwoFactor, false, ""},
+	{"/2fa", "POST", webapp.EnrollTwoFactor, false, ""},
+	{"/2fa/confirm", "POST", webapp.ConfirmTwoFactor, false, ""},
+	{"/2fa", "DELETE", webapp.DisableTwoFactor, false, ""},
+
+	{"/user", "POST", webapp.CreateUser, true, ""},
+	{"/user", "GET", webapp.RetrieveUsers, false, ""},
+	{"/user/{id:[0-9]+}", "GET", webapp.RetrieveUser, false, ""},
+	{"/user/{email}", "GET", webapp.RetrieveUserByEmail, false, ""},
+	{"/user/{id:[0-9]+}", "DELETE", webapp.DeleteUser, false, ""},
+	{"/user/{id:[0-9]+}", "PUT", webapp.UpdateUser, false, ""},
+	{"/user/{id:[0-9]+}/role", "PUT", webapp.UpdateUserRole, false,
+		model.PermManageUsers},
+
+	{"/question", "POST", webapp.CreateQuestion, false, ""},
+	{"/question", "GET", webapp.RetrieveQuestions, false, ""},
+	{"/question/{id:[0-9]+}", "GET", webapp.RetrieveQuestion, false, ""},
+	{"/question/{id:[0-9]+}", "PUT", webapp.UpdateQuestion, false, ""},
+	{"/question/{id:[0-9]+}", "DELETE", webapp.DeleteQuestion, false, ""},
+	{"/question/{id:[0-9]+}/vote", "PUT", webapp.UpVoteQuestion, false, ""},
+	{"/question/{id:[0-9]+}/vote", "DELETE", webapp.DownVoteQuestion, false,
+		""},
+	{"/question/{id:[0-9]+}/revisions", "GET",
+		webapp.RetrieveQuestionRevisions, false, ""},
+	{"/question/{id:[0-9]+}/revisions/{rev:[0-9]+}", "GET",
+		webapp.RetrieveQuestionRevision, false, ""},
+	{"/question/{id:[0-9]+}/revisions/{from:[0-9]+}/diff/{to:[0-9]+}", "GET",
+		webapp.DiffQuestionRevisions, false, ""},
+	{"/question/{id:[0-9]+}/revisions/{rev:[0-9]+}/rollback", "PUT",
+		webapp.RollbackQuestion, false, ""},
+
+	{"/search", "GET", webapp.Search, false, ""},
+
+	{"/tags", "GET", webapp.RetrieveTags, false, ""},
+	{"/tags/{name}", "GET", webapp.RetrieveTag, false, ""},
+	{"/tags/{name}", "PUT", webapp.UpdateTag, false, model.PermManageTags},
+	{"/tags/{name}/merge", "PUT", webapp.MergeTag, false,
+		model.PermManageTags},
+
+	{"/question/{id:[0-9]+}/answers", "POST", webapp.CreateQuestionAnswer,
+		false, ""},
+	{"/question/{id:[0-9]+}/answers", "GET", webapp.RetrieveQuestionAnswers,
+		false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}", "GET",
+		webapp.RetrieveQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}", "PUT",
+		webapp.UpdateQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}", "DELETE",
+		webapp.DeleteQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/accept", "PUT",
+		webapp.AcceptQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/accept", "DELETE",
+		webapp.UnacceptQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/vote", "PUT",
+		webapp.UpVoteQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/vote", "DELETE",
+		webapp.DownVoteQuestionAnswer, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/comments", "POST",
+		webapp.CreateAnswerComments, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/comments", "GET",
+		webapp.RetrieveAnswerComments, false, ""},
+	{"/question/{id:[0-9]+}/answers/{aid:[0-9]+}/revisions", "GET",
+		webapp.RetrieveAnswerRevisions, false, ""},
 
 	{"/question/{id:[0-9]+}/comments", "POST", webapp.CreateQuestionComments,
-		false},
+		false, ""},
 	{"/question/{id:[0-9]+}/comments", "GET", webapp.RetrieveQuestionComments,
-		false},
+		false, ""},
 	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}", "GET",
-		webapp.RetrieveQuestionComment, false},
+		webapp.RetrieveQuestionComment, false, ""},
 	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}", "PUT",
-		webapp.UpdateQuestionComment, false},
+		webapp.UpdateQuestionComment, false, ""},
+	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}", "DELETE",
+		webapp.DeleteQuestionComment, false, ""},
 	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}/vote", "PUT",
-		webapp.UpVoteQuestionComment, false},
+		webapp.UpVoteQuestionComment, false, ""},
 	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}/vote", "DELETE",
-		webapp.DownVoteQuestionComment, false},
+		webapp.DownVoteQuestionComment, false, ""},
+	{"/question/{id:[0-9]+}/comments/{cid:[0-9]+}/revisions", "GET",
+		webapp.RetrieveCommentRevisions, false, ""},
 }
 
 func (app *app) registerRoutes(logger func(appHandler) http.Handler) {
 
 	for _, route := range app.routes {
-		app.router.Handle(route.pattern, logger(route.handler)).
+		app.router.Handle(route.pattern,
+			logger(authorize(route.perm, route.scope(), route.handler))).
 			Methods(route.method)
 	}
 
 }
 
+// scope is the API token scope the route requires: admin for routes
+// requiring a permission, vote for votes, read for other GET routes and write
+// for the rest. Public routes require none.
+func (rt route) scope() string {
+	switch {
+	case rt.public:
+		return ""
+	case rt.perm != "":
+		return model.ScopeAdmin
+	case strings.HasSuffix(rt.pattern, "/vote"):
+		return model.ScopeVote
+	case rt.method == "GET":
+		return model.ScopeRead
+	}
+	return model.ScopeWrite
+}
+
 func (app *app) isPublic(r *http.Request) bool {
 	for _, route := range app.routes {
 		if route.pattern == r.URL.Path && r.Method == route.method &&
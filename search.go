@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+)
+
+func (app *app) Search(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	search := r.URL.Query().Get("q")
+	if search == "" {
+		return nil, errors.Wrap(errMissingParam, "q")
+	}
+	query, err := queryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	results, next, err := app.Storage.Search(r.Context(), search, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// answers and comments are shown under the title of their question
+	for i := range results {
+		if results[i].Kind == model.QuestionVote {
+			continue
+		}
+		question, err := app.Storage.FindQuestion(r.Context(), results[i].QuestionID)
+		if err != nil {
+			return nil, err
+		}
+		results[i].Title = question.Title
+	}
+
+	return paged{results, next}, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// sessionExpiration is the lifetime of a login, refreshing does not extend it
+const sessionExpiration = 30 * 24 * time.Hour
+
+// tokens answers a login or a refresh, the refresh token is single use and
+// the next one comes with the renewed access token
+type tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// startSession opens a session for user and issues its first tokens
+func (app *app) startSession(ctx context.Context,
+	user model.User) (tokens, error) {
+
+	id, err := randomToken(16)
+	if err != nil {
+		return tokens{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return tokens{}, err
+	}
+
+	session, err := app.Storage.CreateSession(ctx, model.Session{
+		ID:      id,
+		UserID:  user.ID,
+		Token:   hashToken(secret),
+		Expires: time.Now().Add(sessionExpiration),
+	})
+	if err != nil {
+		return tokens{}, err
+	}
+
+	return app.issue(session, user, secret)
+}
+
+// issue signs an access token of session for user, refresh tokens are the
+// session id and its secret so a token can be checked against its session
+func (app *app) issue(session model.Session, user model.User,
+	secret string) (tokens, error) {
+
+	// the role claim informs clients, requests are authorized with the role
+	// stored at the time they are served
+	jti, err := randomToken(16)
+	if err != nil {
+		return tokens{}, err
+	}
+	payload := jwt.Payload{
+		Iss:   app.claims.Issuer,
+		Sub:   strconv.Itoa(user.ID),
+		Aud:   jwt.Audience{app.claims.Audience},
+		Exp:   time.Now().Add(jwt.DefaultExpiration).Unix(),
+		Jti:   jti,
+		Email: user.Email,
+		Role:  user.Role,
+		Sid:   session.ID,
+	}
+
+	access, err := jwt.New(payload, app.keys).Encode()
+	if err != nil {
+		return tokens{}, err
+	}
+
+	return tokens{
+		AccessToken:  access,
+		RefreshToken: session.ID + "." + secret,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(jwt.DefaultExpiration / time.Second),
+	}, nil
+}
+
+func (app *app) RefreshToken(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var refresh struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := jsonFromRequest(&refresh, r); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(refresh.RefreshToken, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidRefresh
+	}
+	id, secret := parts[0], parts[1]
+
+	session, err := app.Storage.FindSession(r.Context(), id)
+	if errors.Cause(err) == storage.ErrSessionNotFound {
+		return nil, errInvalidRefresh
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.Active() {
+		return nil, errInvalidRefresh
+	}
+
+	// a rotated token presented again means it leaked, the session is
+	// revoked so neither the thief nor the user can go on with it. Any other
+	// secret is rejected alone, session ids are no secret.
+	old := hashToken(secret)
+	if subtle.ConstantTimeCompare([]byte(old), []byte(session.Token)) != 1 {
+		if session.Reused(old) {
+			return nil, app.revokeReused(r.Context(), id)
+		}
+		return nil, errInvalidRefresh
+	}
+
+	next, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	session, err = app.Storage.RotateSession(r.Context(), id, old,
+		hashToken(next))
+	if errors.Cause(err) == storage.ErrSessionNotFound {
+		return nil, app.revokeReused(r.Context(), id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := app.Storage.FindUser(r.Context(), session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.issue(session, user, next)
+}
+
+// revokeReused ends a session whose refresh token was used twice
+func (app *app) revokeReused(ctx context.Context, id string) error {
+	if err := app.Storage.RevokeSession(ctx, id); err != nil {
+		return err
+	}
+	return errRefreshReused
+}
+
+func (app *app) Logout(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	p := principalFrom(r)
+	if p.Session == "" {
+		return nil, errors.Wrap(model.ErrForbidden, "API tokens cannot log out")
+	}
+	return nil, app.Storage.RevokeSession(r.Context(), p.Session)
+}
+
+// JWKS publishes the public keys access tokens are verified with
+func (app *app) JWKS(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return bare{app.keys.JWKS()}, nil
+}
+
+// randomToken returns n random bytes encoded for urls
+func randomToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "cannot generate token")
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is the stored form of a refresh token secret, secrets are random
+// enough for a plain digest
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
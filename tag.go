@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+)
+
+func (app *app) RetrieveTags(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	return app.Storage.FindAllTag(r.Context())
+}
+
+func (app *app) RetrieveTag(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	name, err := tagFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindTag(r.Context(), name)
+}
+
+func (app *app) UpdateTag(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var tag model.Tag
+	if err := jsonFromRequest(&tag, r); err != nil {
+		return nil, err
+	}
+	name, err := tagFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	tag.Name = name
+
+	return app.Storage.UpdateTag(r.Context(), tag)
+}
+
+func (app *app) MergeTag(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var merge struct {
+		Into string `json:"into"`
+	}
+	if err := jsonFromRequest(&merge, r); err != nil {
+		return nil, err
+	}
+	name, err := tagFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.Storage.MergeTag(r.Context(), name, merge.Into); err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindTag(r.Context(), name)
+}
+
+func tagFromRequest(r *http.Request) (string, error) {
+	params := mux.Vars(r)
+	name, exist := params["name"]
+	if !exist {
+		return "", errors.Wrap(errMissingParam, "name")
+	}
+	return name, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+)
+
+const (
+	// emailFailures and ipFailures are the failed logins allowed before a
+	// lockout, an IP address may be shared by several users
+	emailFailures = 5
+	ipFailures    = 20
+
+	// lockouts start at baseLockout and double with every failure past the
+	// threshold up to maxLockout, failures older than failureWindow are
+	// forgotten
+	baseLockout   = 30 * time.Second
+	maxLockout    = time.Hour
+	failureWindow = 24 * time.Hour
+)
+
+// lockedFor returns how long a throttle locks logins after its last failure
+func lockedFor(t model.LoginThrottle, threshold int) time.Duration {
+	over := t.Failures - threshold
+	if over < 0 {
+		return 0
+	}
+	if over >= 8 {
+		return maxLockout
+	}
+	if d := baseLockout << uint(over); d < maxLockout {
+		return d
+	}
+	return maxLockout
+}
+
+// loginEmail is the form of an email throttled and audited
+func loginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func emailKey(email string) string {
+	return "email:" + loginEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// clientIP is the address of the peer of r
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// checkLogin fails with errLoginLocked while the email or the IP address of r
+// is locked out, the same way whether or not a user has the email
+func (app *app) checkLogin(w http.ResponseWriter, r *http.Request,
+	email string) error {
+
+	ip := clientIP(r)
+	retry := time.Duration(0)
+	for key, threshold := range map[string]int{
+		emailKey(email): emailFailures,
+		ipKey(ip):       ipFailures,
+	} {
+		throttle, err := app.Storage.FindLoginThrottle(r.Context(), key)
+		if err != nil {
+			return err
+		}
+		left := time.Until(throttle.Last.Add(lockedFor(throttle, threshold)))
+		if left > retry {
+			retry = left
+		}
+	}
+	if retry <= 0 {
+		return nil
+	}
+
+	app.auditLogin(r.Context(), email, ip, model.LoginLocked)
+	seconds := int((retry + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return errors.Wrapf(errLoginLocked, "retry in %d seconds", seconds)
+}
+
+// failLogin records a failed login against the email and the IP address of r
+func (app *app) failLogin(r *http.Request, email, reason string) error {
+	ip := clientIP(r)
+	app.auditLogin(r.Context(), email, ip, reason)
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		if _, err := app.Storage.FailLogin(r.Context(), key,
+			failureWindow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditLogin keeps the record of a failed login, the login fails all the same
+// when it cannot be stored
+func (app *app) auditLogin(ctx context.Context, email, ip, reason string) {
+	if _, err := app.Storage.CreateLoginAttempt(ctx, model.LoginAttempt{
+		Email:  loginEmail(email),
+		IP:     ip,
+		Reason: reason,
+	}); err != nil {
+		log.Printf("E: cannot audit login of %s from %s: %+v\n", email, ip,
+			err)
+	}
+}
+
+// RetrieveLoginAttempts lists the failed logins of an email
+func (app *app) RetrieveLoginAttempts(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		return nil, errors.Wrap(errMissingParam, "email")
+	}
+	query, err := queryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	attempts, next, err := app.Storage.FindLoginAttempts(r.Context(),
+		loginEmail(email), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return paged{attempts, next}, nil
+}
+
+// UnlockLogin lifts the lockout of an email before it expires
+func (app *app) UnlockLogin(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var unlock struct {
+		Email string `json:"email"`
+	}
+	if err := jsonFromRequest(&unlock, r); err != nil {
+		return nil, err
+	}
+	if unlock.Email == "" {
+		return nil, errors.Wrap(errMissingParam, "email")
+	}
+
+	return nil, app.Storage.ClearLoginThrottle(r.Context(),
+		emailKey(unlock.Email))
+}
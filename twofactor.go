@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/argon2"
+	"securecodewarrior.com/ddias/heapoverflow/crypto/totp"
+	"securecodewarrior.com/ddias/heapoverflow/jwt"
+	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+const (
+	// challengeAudience keeps challenge tokens from being used as access
+	// tokens and the other way around
+	challengeAudience   = "2fa"
+	challengeExpiration = 5 * time.Minute
+
+	recoveryCodes    = 10
+	recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+	// codeSkew accepts the codes of the previous and next time steps
+	codeSkew = 1
+)
+
+// challenge answers the login of a user with two-factor authentication, the
+// token is exchanged for a session along with a code at /login/2fa
+type challenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type code struct {
+	Code string `json:"code"`
+}
+
+func (app *app) RetrieveTwoFactor(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.FindTwoFactor(r.Context(), user.ID)
+}
+
+// EnrollTwoFactor generates a new secret, two-factor authentication is only
+// enabled once a code of the secret is confirmed
+func (app *app) EnrollTwoFactor(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := app.Storage.FindTwoFactor(r.Context(), user.ID)
+	if err == nil && stored.Enabled {
+		return nil, errTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := app.Storage.SaveTwoFactor(r.Context(), model.TwoFactor{
+		UserID: user.ID,
+		Secret: secret,
+	}); err != nil {
+		return nil, err
+	}
+
+	return enrollment{secret, totp.URI(app.claims.Issuer, user.Email,
+		secret)}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication and answers the recovery
+// codes, the only time they are shown
+func (app *app) ConfirmTwoFactor(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	var confirm code
+	if err := jsonFromRequest(&confirm, r); err != nil {
+		return nil, err
+	}
+
+	stored, err := app.Storage.FindTwoFactor(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Enabled {
+		return nil, errTwoFactorEnabled
+	}
+	step, ok := totp.Validate(stored.Secret, confirm.Code, time.Now(),
+		codeSkew)
+	if !ok {
+		return nil, errInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	enabled := stored
+	enabled.Enabled = true
+	enabled.LastStep = step
+	enabled.Recovery = strings.Join(hashes, " ")
+	if err := app.Storage.UpdateTwoFactor(r.Context(), stored,
+		enabled); err != nil {
+		return nil, err
+	}
+
+	return map[string][]string{"recovery_codes": codes}, nil
+}
+
+// DisableTwoFactor requires a code or a recovery code so a stolen session
+// cannot turn it off
+func (app *app) DisableTwoFactor(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	user, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	var disable code
+	if err := jsonFromRequest(&disable, r); err != nil {
+		return nil, err
+	}
+
+	stored, err := app.Storage.FindTwoFactor(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Enabled {
+		if err := app.verifyCode(r.Context(), stored,
+			disable.Code); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, app.Storage.DeleteTwoFactor(r.Context(), user.ID)
+}
+
+// LoginTwoFactor completes a login challenge with a code or a recovery code
+func (app *app) LoginTwoFactor(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var login struct {
+		ChallengeToken string `json:"challenge_token"`
+		code
+	}
+	if err := jsonFromRequest(&login, r); err != nil {
+		return nil, err
+	}
+
+	user, err := app.verifyChallenge(r.Context(), login.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.checkLogin(w, r, user.Email); err != nil {
+		return nil, err
+	}
+	stored, err := app.Storage.FindTwoFactor(r.Context(), user.ID)
+	if err != nil || !stored.Enabled {
+		return nil, errInvalidChallenge
+	}
+	if err := app.verifyCode(r.Context(), stored, login.Code); err != nil {
+		if errors.Cause(err) == errInvalidCode {
+			if err := app.failLogin(r, user.Email,
+				model.LoginInvalidCode); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	if err := app.Storage.ClearLoginThrottle(r.Context(),
+		emailKey(user.Email)); err != nil {
+		return nil, err
+	}
+
+	return app.startSession(r.Context(), user)
+}
+
+// twoFactorLogin returns the challenge of user when two-factor
+// authentication is enabled, nil when the password is enough
+func (app *app) twoFactorLogin(ctx context.Context,
+	user model.User) (*challenge, error) {
+
+	stored, err := app.Storage.FindTwoFactor(ctx, user.ID)
+	if errors.Cause(err) == storage.ErrTwoFactorNotFound ||
+		(err == nil && !stored.Enabled) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	payload := jwt.Payload{
+		Iss: app.claims.Issuer,
+		Sub: strconv.Itoa(user.ID),
+		Aud: jwt.Audience{challengeAudience},
+		Exp: time.Now().Add(challengeExpiration).Unix(),
+		Jti: jti,
+	}
+	token, err := jwt.New(payload, app.keys).Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge{token, int(challengeExpiration / time.Second)}, nil
+}
+
+// verifyChallenge returns the user a challenge token was issued to
+func (app *app) verifyChallenge(ctx context.Context,
+	raw string) (model.User, error) {
+
+	token := jwt.New(jwt.Payload{}, app.keys)
+	if err := token.Decode(raw); err != nil {
+		return model.User{}, errors.Wrap(errInvalidChallenge, err.Error())
+	}
+	if err := token.Check(jwt.Validator{
+		Issuer:   app.claims.Issuer,
+		Audience: challengeAudience,
+		Leeway:   app.claims.Leeway,
+	}); err != nil {
+		return model.User{}, errors.Wrap(errInvalidChallenge, err.Error())
+	}
+
+	id, err := strconv.Atoi(token.Claims().Sub)
+	if err != nil {
+		return model.User{}, errInvalidChallenge
+	}
+	user, err := app.Storage.FindUser(ctx, id)
+	if errors.Cause(err) == storage.ErrUserNotFound {
+		return model.User{}, errInvalidChallenge
+	}
+	return user, err
+}
+
+// verifyCode accepts a code of a time step after the last one used or an
+// unused recovery code, both are spent
+func (app *app) verifyCode(ctx context.Context, stored model.TwoFactor,
+	code string) error {
+
+	spent := stored
+	step, ok := totp.Validate(stored.Secret, code, time.Now(), codeSkew)
+	switch {
+	case ok && step > stored.LastStep:
+		spent.LastStep = step
+	case !ok:
+		hashes, used := useRecoveryCode(stored.RecoveryHashes(), code)
+		if !used {
+			return errInvalidCode
+		}
+		spent.Recovery = strings.Join(hashes, " ")
+	default:
+		return errInvalidCode
+	}
+
+	err := app.Storage.UpdateTwoFactor(ctx, stored, spent)
+	if errors.Cause(err) == storage.ErrTwoFactorNotFound {
+		return errInvalidCode
+	}
+	return err
+}
+
+// newRecoveryCodes returns recovery codes and their argon2 hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodes)
+	hashes := make([]string, recoveryCodes)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, errors.Wrap(err,
+				"cannot generate recovery code")
+		}
+		code := make([]byte, len(raw))
+		for j, b := range raw {
+			code[j] = recoveryAlphabet[int(b)%len(recoveryAlphabet)]
+		}
+		codes[i] = string(code[:5]) + "-" + string(code[5:])
+
+		var err error
+		hashes[i], err = argon2.GenerateFromPassword(code, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return codes, hashes, nil
+}
+
+// useRecoveryCode returns the hashes left once code is spent and whether code
+// matched one of them. Only codes of the xxxxx-xxxxx form are hashed so a
+// mistyped TOTP code costs no argon2 comparison.
+func useRecoveryCode(hashes []string, code string) ([]string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != 11 || code[5] != '-' {
+		return hashes, false
+	}
+	code = code[:5] + code[6:]
+	for _, c := range code {
+		if !strings.ContainsRune(recoveryAlphabet, c) {
+			return hashes, false
+		}
+	}
+
+	for i, hash := range hashes {
+		if argon2.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			return append(hashes[:i:i], hashes[i+1:]...), true
+		}
+	}
+	return hashes, false
+}
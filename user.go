@@ -5,8 +5,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	"securecodewarrior.com/ddias/heapoverflow/jwt"
 	"securecodewarrior.com/ddias/heapoverflow/model"
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
 )
 
 func (app *app) CreateUser(w http.ResponseWriter,
@@ -18,18 +18,34 @@ func (app *app) CreateUser(w http.ResponseWriter,
 		return nil, err
 	}
 
-	payload := jwt.DecodePayload(r)
-	if payload.Email != "" {
-		return nil, errors.Errorf("Already logged")
+	if _, err := requestUser(r); err == nil {
+		return nil, errors.Wrap(model.ErrForbidden, "Already logged")
 	}
 
-	return app.Storage.CreateUser(user)
+	created, err := app.Storage.CreateUser(r.Context(), user)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.sendVerification(created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
 }
 
 func (app *app) RetrieveUsers(w http.ResponseWriter,
 	r *http.Request) (interface{}, error) {
 
-	return app.Storage.FindAllUser()
+	query, err := queryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	users, next, err := app.Storage.FindAllUser(r.Context(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return paged{users, next}, nil
 }
 
 func (app *app) RetrieveUser(w http.ResponseWriter,
@@ -40,7 +56,7 @@ func (app *app) RetrieveUser(w http.ResponseWriter,
 		return nil, err
 	}
 
-	return app.Storage.FindUser(id)
+	return app.Storage.FindUser(r.Context(), id)
 }
 
 func (app *app) RetrieveUserByEmail(w http.ResponseWriter,
@@ -49,10 +65,10 @@ func (app *app) RetrieveUserByEmail(w http.ResponseWriter,
 	params := mux.Vars(r)
 	email, exist := params["email"]
 	if !exist {
-		return nil, errors.Errorf("Missing email parameter")
+		return nil, errors.Wrap(errMissingParam, "email")
 	}
 
-	user, err := app.Storage.FindUserByEmail(email)
+	user, err := app.Storage.FindUserByEmail(r.Context(), email)
 	if err != nil {
 		return nil, err
 	}
@@ -69,17 +85,14 @@ func (app *app) DeleteUser(w http.ResponseWriter,
 		return nil, err
 	}
 
-	user, err := app.Storage.FindUser(id)
-	if err != nil {
+	if _, err := app.Storage.FindUser(r.Context(), id); err != nil {
 		return nil, err
 	}
-
-	payload := jwt.DecodePayload(r)
-	if payload.Email != user.Email {
-		return nil, errors.Errorf("Cannot delete another user")
+	if err := app.ownerOr(r, id, model.PermManageUsers); err != nil {
+		return nil, errors.Wrap(err, "Cannot delete another user")
 	}
 
-	return nil, app.Storage.DeleteUser(id)
+	return nil, app.Storage.DeleteUser(r.Context(), id)
 }
 
 func (app *app) UpdateUser(w http.ResponseWriter,
@@ -91,19 +104,37 @@ func (app *app) UpdateUser(w http.ResponseWriter,
 		return nil, err
 	}
 
-	payload := jwt.DecodePayload(r)
-	if payload.Email != user.Email {
-		return nil, errors.Errorf("Cannot update another user")
-	}
-
 	id, err := idFromRequest("id", r)
 	if err != nil {
 		return nil, err
 	}
+	if _, err := app.Storage.FindUser(r.Context(), id); err != nil {
+		return nil, err
+	}
+	if err := app.ownerOr(r, id, model.PermManageUsers); err != nil {
+		return nil, errors.Wrap(err, "Cannot update another user")
+	}
 
 	user.ID = id
 
-	return app.Storage.UpdateUser(user)
+	return app.Storage.UpdateUser(r.Context(), user)
+}
+
+func (app *app) UpdateUserRole(w http.ResponseWriter,
+	r *http.Request) (interface{}, error) {
+
+	var grant struct {
+		Role string `json:"role"`
+	}
+	if err := jsonFromRequest(&grant, r); err != nil {
+		return nil, err
+	}
+	id, err := idFromRequest("id", r)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Storage.SetUserRole(r.Context(), id, grant.Role)
 }
 
 func (app *app) Login(w http.ResponseWriter,
@@ -115,15 +146,37 @@ func (app *app) Login(w http.ResponseWriter,
 		return nil, err
 	}
 
-	if err := app.Storage.Login(user.Email, user.Password); err != nil {
+	if err := app.checkLogin(w, r, user.Email); err != nil {
+		return nil, err
+	}
+	if err := app.Storage.Login(r.Context(), user.Email,
+		user.Password); err != nil {
+		if errors.Cause(err) == storage.ErrInvalidCredentials {
+			if err := app.failLogin(r, user.Email,
+				model.LoginInvalidCredentials); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	stored, err := app.Storage.FindUserByEmail(r.Context(), user.Email)
+	if err != nil {
 		return nil, err
 	}
 
-	payload := jwt.Payload{
-		Email: user.Email,
-		Exp:   jwt.DefaultExpiration,
+	// the password alone is not enough with two-factor authentication
+	challenge, err := app.twoFactorLogin(r.Context(), stored)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+	if err := app.Storage.ClearLoginThrottle(r.Context(),
+		emailKey(user.Email)); err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewFromFile(payload, app.jwtKeyFile)
-	return token.Encode()
+	return app.startSession(r.Context(), stored)
 }
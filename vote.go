@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"securecodewarrior.com/ddias/heapoverflow/model/storage"
+)
+
+// ballot returns the direction the request user voted on target, 0 if none
+func (app *app) ballot(r *http.Request, target string, id int) (int, error) {
+	user, err := requestUser(r)
+	if err != nil {
+		return 0, err
+	}
+
+	vote, err := app.Storage.FindVote(r.Context(), user.ID, target, id)
+	if err == storage.ErrVoteNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return vote.Direction, nil
+}
+
+// ballots maps every target id voted by the request user to its direction
+func (app *app) ballots(r *http.Request, target string) (map[int]int, error) {
+	user, err := requestUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := app.Storage.FindVoteByUser(r.Context(), user.ID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	voted := make(map[int]int, len(votes))
+	for _, vote := range votes {
+		voted[vote.TargetID] = vote.Direction
+	}
+	return voted, nil
+}